@@ -2,15 +2,29 @@ package main
 
 import (
 	"flag"
-	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"strconv"
 	"time"
 
 	"wireguard-vpn-client-creater/internal/api"
 	"wireguard-vpn-client-creater/pkg/config"
 	"wireguard-vpn-client-creater/pkg/database"
+	"wireguard-vpn-client-creater/pkg/ipam"
+	"wireguard-vpn-client-creater/pkg/monitor"
+	"wireguard-vpn-client-creater/pkg/reconcile"
+	"wireguard-vpn-client-creater/pkg/security"
+	"wireguard-vpn-client-creater/pkg/webhook"
+	"wireguard-vpn-client-creater/pkg/wireguard"
 )
 
+// certReloadInterval - TLS sertifikat fayllari o'zgarishini tekshirish oralig'i
+const certReloadInterval = 10 * time.Second
+
+// proxyHeaderTimeout - Ulanish boshida PROXY protocol sarlavhasini kutishning maksimal vaqti
+const proxyHeaderTimeout = 10 * time.Second
+
 // Muddati o'tgan clientlarni tekshirish uchun scheduler
 func startExpirationChecker() {
 	ticker := time.NewTicker(15 * time.Minute) // Har 15 minutda bir marta tekshirish
@@ -22,6 +36,51 @@ func startExpirationChecker() {
 				if err := database.DeleteExpiredClients(); err != nil {
 					log.Printf("Muddati o'tgan clientlarni tekshirishda xatolik: %v", err)
 				}
+				notifyExpiringClients()
+				if err := database.DeleteExpiredSessions(); err != nil {
+					log.Printf("Muddati o'tgan sessiyalarni tozalashda xatolik: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Muddati yaqinda tugaydigan clientlar haqida ogohlantirish
+func notifyExpiringClients() {
+	window := time.Duration(config.Config.Webhooks.ExpiringSoon) * time.Hour
+	if window <= 0 {
+		return
+	}
+
+	if err := database.NotifyExpiringClients(window); err != nil {
+		log.Printf("Muddati tugayotgan clientlarni tekshirishda xatolik: %v", err)
+	}
+}
+
+// Database va interface o'rtasidagi farqlarni tekshirish uchun scheduler
+func startDriftReconciler() {
+	reconcilerConfig := config.Config.Reconciler
+
+	interval := time.Duration(reconcilerConfig.Interval) * time.Minute
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			report, err := reconcile.Run(wireguard.Current, reconcilerConfig.AutoRepair)
+			if err != nil {
+				log.Printf("Drift tekshiruvida xatolik: %v", err)
+				continue
+			}
+
+			if !report.InSync {
+				log.Printf("Drift aniqlandi: orphan=%d, missing=%d, mismatched=%d, tuzatildi=%t",
+					len(report.OrphanPeers), len(report.MissingPeers), len(report.Mismatched), report.Repaired)
+				for _, repairErr := range report.RepairErrors {
+					log.Printf("Drift tuzatishda xatolik: %s", repairErr)
+				}
 			}
 		}
 	}()
@@ -48,24 +107,76 @@ func main() {
 	}
 	log.Printf("Konfiguratsiya fayli o'qildi: %s", *configPath)
 
+	// IP manzillar poollarini yuklash
+	if err := ipam.InitPools(); err != nil {
+		log.Fatalf("IP manzillar poollarini yuklashda xatolik: %v", err)
+	}
+
+	// Wireguard backendini ishga tushirish
+	if _, err := wireguard.InitBackend(config.Config.Wireguard.Backend); err != nil {
+		log.Fatalf("Wireguard backendini ishga tushirishda xatolik: %v", err)
+	}
+
 	// Databaseni ishga tushirish
 	_, err := database.InitDB(config.Config.Database.Path)
 	if err != nil {
 		log.Fatalf("Database initializatsiyasida xatolik: %v", err)
 	}
 
+	// netlink backend peerlarni wg0.conf fayliga yozmaydi, shuning uchun ular databasedan tiklanadi
+	if config.Config.Wireguard.Backend == wireguard.BackendNetlink {
+		restored, err := reconcile.Restore(wireguard.Current)
+		if err != nil {
+			log.Printf("Peerlarni databasedan tiklashda xatolik: %v", err)
+		} else {
+			log.Printf("Peerlar databasedan tiklandi: %d ta", restored)
+		}
+		if !config.Config.Reconciler.Enabled || !config.Config.Reconciler.AutoRepair {
+			log.Println("Ogohlantirish: netlink backend drift tekshiruvi auto_repair siz ishlamoqda, server ishlayotganda interface qayta ishga tushsa peerlar tiklanmaydi")
+		}
+	}
+
+	// Webhooklarni ishga tushirish (navbatdagi yetkazishlar ham davom ettiriladi)
+	webhook.Start()
+	if n := len(config.Config.Webhooks.Endpoints); n > 0 {
+		log.Printf("Webhooklar ishga tushirildi. Endpointlar soni: %d", n)
+	}
+
 	// IP bloklash tizimini ishga tushirish
 	if config.Config.Security.IPBlocker.Enabled {
 		if err := api.InitIPBlocker(); err != nil {
 			log.Fatalf("IP bloklash tizimini ishga tushirishda xatolik: %v", err)
 		}
-		log.Printf("IP bloklash tizimi ishga tushirildi. Maksimal urinishlar: %d, Bloklash muddati: %d minut",
+		log.Printf("IP bloklash tizimi ishga tushirildi. Maksimal urinishlar: %d (%d minut ichida), Bloklash muddatlari: %v minut",
 			config.Config.Security.IPBlocker.MaxAttempts,
-			config.Config.Security.IPBlocker.BlockDuration)
+			config.Config.Security.IPBlocker.FailureWindow,
+			config.Config.Security.IPBlocker.BanDurations)
 	} else {
 		log.Println("IP bloklash tizimi o'chirilgan")
 	}
 
+	// So'rovlar chastotasini cheklash (login chegarasi doim ishlaydi)
+	api.InitRateLimiter()
+	if rateLimit := config.Config.Security.RateLimit; rateLimit.Enabled {
+		log.Printf("So'rovlar chastotasini cheklash yoqildi. Minutiga: read %d, write %d, admin %d, client_create %d",
+			rateLimit.Read.Rate, rateLimit.Write.Rate, rateLimit.Admin.Rate, rateLimit.ClientCreate.Rate)
+	} else {
+		log.Println("So'rovlar chastotasini cheklash o'chirilgan (login chegarasidan tashqari)")
+	}
+
+	// /metrics client tavsiflari va manzillarini ochib beradi
+	if config.Config.Metrics.Enabled && config.Config.Metrics.Token == "" {
+		log.Println("Ogohlantirish: /metrics tokensiz ochiq, u client tavsiflari va manzillarini ko'rsatadi. metrics.token ni sozlang")
+	}
+
+	// Tashqi identity provider tokenlarini qabul qilish
+	if config.Config.Auth.JWT.Enabled {
+		if err := api.InitJWTVerifier(); err != nil {
+			log.Fatalf("JWT autentifikatsiyasini sozlashda xatolik: %v", err)
+		}
+		log.Printf("JWT autentifikatsiyasi yoqildi. Issuer: %s", config.Config.Auth.JWT.Issuer)
+	}
+
 	// Muddati o'tgan clientlarni tekshirish schedulerini ishga tushirish
 	startExpirationChecker()
 
@@ -73,14 +184,65 @@ func main() {
 	if err := database.DeleteExpiredClients(); err != nil {
 		log.Printf("Muddati o'tgan clientlarni tekshirishda xatolik: %v", err)
 	}
+	notifyExpiringClients()
+
+	// Drift tekshiruvi schedulerini ishga tushirish
+	if config.Config.Reconciler.Enabled {
+		startDriftReconciler()
+		log.Printf("Drift tekshiruvi ishga tushirildi. Avtomatik tuzatish: %t", config.Config.Reconciler.AutoRepair)
+	}
+
+	// Peer statistikasi monitorini ishga tushirish (traffic hisobi va kvotalar)
+	monitor.Start(wireguard.Current, time.Duration(config.Config.Monitor.Interval)*time.Second)
+	log.Printf("Peer monitori ishga tushirildi. Kvota siyosati: %s", config.Config.Quota.Policy)
+
+	// Ishonchli proxylar (client IP sarlavhalari va PROXY protocol faqat ulardan qabul qilinadi)
+	trustedProxies, err := security.ParseCIDRs(config.Config.Security.TrustedProxies)
+	if err != nil {
+		log.Fatalf("security.trusted_proxies: %v", err)
+	}
+	if config.Config.Security.ProxyProtocol && len(trustedProxies) == 0 {
+		log.Fatalf("security.proxy_protocol uchun security.trusted_proxies ko'rsatilishi kerak")
+	}
 
 	// API routerini sozlash
 	r := api.SetupRouter()
 
 	// Serverni ishga tushirish
-	serverAddr := fmt.Sprintf(":%d", config.Config.API.Port)
-	log.Printf("Server started on %s", serverAddr)
-	if err := r.Run(serverAddr); err != nil {
+	server := &http.Server{
+		Addr:              net.JoinHostPort(config.Config.API.Bind, strconv.Itoa(config.Config.API.Port)),
+		Handler:           r.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatalf("Server ishga tushirishda xatolik: %v", err)
+	}
+	if config.Config.Security.ProxyProtocol {
+		listener = &security.ProxyProtocolListener{Listener: listener, Trusted: trustedProxies, Timeout: proxyHeaderTimeout}
+		log.Printf("PROXY protocol yoqildi. Ishonchli proxylar: %v", config.Config.Security.TrustedProxies)
+	}
+
+	tlsConfig := config.Config.API.TLS
+	if tlsConfig.CertFile == "" {
+		log.Printf("Server started on %s", server.Addr)
+		if err := server.Serve(listener); err != nil {
+			log.Fatalf("Server ishga tushirishda xatolik: %v", err)
+		}
+		return
+	}
+
+	// HTTPS: sertifikat fayllari o'zgarganda server to'xtatilmasdan qayta yuklanadi
+	reloader, err := security.NewCertReloader(tlsConfig.CertFile, tlsConfig.KeyFile, tlsConfig.ClientCAFile)
+	if err != nil {
+		log.Fatalf("TLS sertifikatini yuklashda xatolik: %v", err)
+	}
+	reloader.Watch(certReloadInterval)
+	server.TLSConfig = reloader.TLSConfig()
+
+	log.Printf("Server started on %s (TLS, mutual TLS: %t)", server.Addr, tlsConfig.ClientCAFile != "")
+	if err := server.ServeTLS(listener, "", ""); err != nil {
 		log.Fatalf("Server ishga tushirishda xatolik: %v", err)
 	}
 }
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wireguard-vpn-client-creater/pkg/auth"
+	"wireguard-vpn-client-creater/pkg/database"
+	"wireguard-vpn-client-creater/pkg/models"
+)
+
+// CreateAPIKeyHandler - Yangi nomlangan API kaliti yaratish
+func CreateAPIKeyHandler(c *gin.Context) {
+	var request struct {
+		Name      string   `json:"name"`
+		Scopes    []string `json:"scopes"`
+		ExpiresIn int      `json:"expires_in"` // Soniyalarda, 0 = cheksiz
+		models.APIKeyLimits
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri so'rov formati"})
+		return
+	}
+
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name ko'rsatilishi kerak"})
+		return
+	}
+	if err := auth.ValidateScopes(request.Scopes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.ExpiresIn < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in manfiy bo'lishi mumkin emas"})
+		return
+	}
+	if err := validateKeyLimits(request.APIKeyLimits); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var expiresAt *time.Time
+	if request.ExpiresIn > 0 {
+		expiry := time.Now().Add(time.Duration(request.ExpiresIn) * time.Second)
+		expiresAt = &expiry
+	}
+
+	token, key, err := database.CreateAPIKey(request.Name, auth.JoinScopes(request.Scopes), auditActor(c), expiresAt,
+		request.APIKeyLimits)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "API kalitini yaratishda xatolik: " + err.Error()})
+		return
+	}
+
+	database.RecordAuditDetails(auditActor(c), c.ClientIP(), database.AuditAPIKeyCreate, nil, gin.H{
+		"id":         key.ID,
+		"name":       key.Name,
+		"scopes":     request.Scopes,
+		"expires_at": key.ExpiresAt,
+		"limits":     key.APIKeyLimits,
+	})
+
+	// Kalitning o'zi faqat bir marta qaytariladi
+	c.JSON(http.StatusOK, gin.H{
+		"key":     token,
+		"data":    key,
+		"message": "API kaliti yaratildi. Kalitni saqlab qo'ying, u qayta ko'rsatilmaydi",
+	})
+}
+
+// GetAPIKeysHandler - Barcha API kalitlari ro'yxatini olish (kalitlarning o'zisiz)
+func GetAPIKeysHandler(c *gin.Context) {
+	keys, err := database.GetAllAPIKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "API kalitlarini olishda xatolik: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// UpdateAPIKeyHandler - API kalitining so'rovlar chegaralarini o'zgartirish
+func UpdateAPIKeyHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri ID formati"})
+		return
+	}
+
+	var request models.APIKeyLimits
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri so'rov formati"})
+		return
+	}
+	if err := validateKeyLimits(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, err := database.UpdateAPIKeyLimits(uint(id), request)
+	if errors.Is(err, database.ErrAPIKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API kaliti topilmadi"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "API kalitini yangilashda xatolik: " + err.Error()})
+		return
+	}
+
+	database.RecordAuditDetails(auditActor(c), c.ClientIP(), database.AuditAPIKeyUpdate, nil, gin.H{
+		"id":     key.ID,
+		"name":   key.Name,
+		"limits": key.APIKeyLimits,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"data": key, "message": "API kaliti yangilandi"})
+}
+
+// RevokeAPIKeyHandler - API kalitini bekor qilish
+func RevokeAPIKeyHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri ID formati"})
+		return
+	}
+
+	key, err := database.RevokeAPIKey(uint(id))
+	if errors.Is(err, database.ErrAPIKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API kaliti topilmadi"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "API kalitini bekor qilishda xatolik: " + err.Error()})
+		return
+	}
+
+	database.RecordAuditDetails(auditActor(c), c.ClientIP(), database.AuditAPIKeyRevoke, nil, gin.H{
+		"id":   key.ID,
+		"name": key.Name,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":         key.ID,
+		"revoked_at": key.RevokedAt,
+		"message":    "API kaliti bekor qilindi",
+	})
+}
+
+// validateKeyLimits - API kaliti chegaralari manfiy emasligini tekshirish
+func validateKeyLimits(limits models.APIKeyLimits) error {
+	if limits.ReadLimit < 0 || limits.WriteLimit < 0 || limits.AdminLimit < 0 || limits.ClientCreateLimit < 0 {
+		return fmt.Errorf("read_limit, write_limit, admin_limit va client_create_limit manfiy bo'lishi mumkin emas")
+	}
+	return nil
+}
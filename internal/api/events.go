@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wireguard-vpn-client-creater/pkg/events"
+)
+
+// eventsHeartbeat - Proxylar ulanishni yopib qo'ymasligi uchun bo'sh xabar oralig'i
+const eventsHeartbeat = 15 * time.Second
+
+// EventsHandler - Hodisalarni Server-Sent Events orqali uzatish.
+// Filtrlar: ?type=client.created,client.online va ?client_id=1,2 (vergul bilan ajratilgan)
+func EventsHandler(c *gin.Context) {
+	types := make(map[string]bool)
+	for _, eventType := range splitQuery(c.Query("type")) {
+		types[eventType] = true
+	}
+
+	clientIDs := make(map[uint]bool)
+	for _, value := range splitQuery(c.Query("client_id")) {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri client_id formati"})
+			return
+		}
+		clientIDs[uint(id)] = true
+	}
+
+	ch, unsubscribe := events.Subscribe()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // nginx buferlashini o'chirish
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			if len(types) > 0 && !types[event.Type] {
+				continue
+			}
+			if len(clientIDs) > 0 && !clientIDs[event.ClientID] {
+				continue
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			c.Writer.Flush()
+		}
+	}
+}
+
+// splitQuery - Vergul bilan ajratilgan query qiymatini ro'yxatga o'tkazish
+func splitQuery(value string) []string {
+	var result []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
@@ -1,7 +1,11 @@
 package api
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"os"
 	"strconv"
@@ -10,9 +14,15 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"wireguard-vpn-client-creater/pkg/auth"
 	"wireguard-vpn-client-creater/pkg/config"
 	"wireguard-vpn-client-creater/pkg/database"
+	"wireguard-vpn-client-creater/pkg/events"
+	"wireguard-vpn-client-creater/pkg/ipam"
+	"wireguard-vpn-client-creater/pkg/metrics"
 	"wireguard-vpn-client-creater/pkg/models"
+	"wireguard-vpn-client-creater/pkg/monitor"
+	"wireguard-vpn-client-creater/pkg/reconcile"
 	"wireguard-vpn-client-creater/pkg/security"
 	"wireguard-vpn-client-creater/pkg/wireguard"
 )
@@ -20,6 +30,15 @@ import (
 // Global IP blocker
 var ipBlocker *security.IPBlocker
 
+// Autentifikatsiyadan o'tgan so'rov ma'lumotlari saqlanadigan gin context kalitlari
+const (
+	ActorContextKey   = "audit_actor"
+	ScopesContextKey  = "auth_scopes"
+	SessionContextKey = "auth_session"
+	UserContextKey    = "auth_user"
+	APIKeyContextKey  = "auth_api_key"
+)
+
 // InitIPBlocker - IP bloklash tizimini ishga tushirish
 func InitIPBlocker() error {
 	// Konfiguratsiyadan IP bloklash sozlamalarini olish
@@ -39,15 +58,63 @@ func InitIPBlocker() error {
 		}
 	}
 
-	// IP bloklash tizimini yaratish
-	var err error
-	ipBlocker, err = security.NewIPBlocker(
-		time.Duration(ipBlockerConfig.BlockDuration)*time.Minute,
-		ipBlockerConfig.MaxAttempts,
-		ipBlockerConfig.LogFilePath,
-	)
+	// Firewall darajasida bloklash (ixtiyoriy)
+	firewallConfig := ipBlockerConfig.Firewall
+	firewall, err := security.NewFirewall(firewallConfig.Backend, firewallConfig.Table, firewallConfig.Set, config.Config.API.Port)
+	if err != nil {
+		return fmt.Errorf("firewallni sozlashda xatolik: %v", err)
+	}
+
+	// IP bloklash tizimini yaratish (saqlangan bloklar databasedan tiklanadi)
+	ipBlocker, err = security.NewIPBlocker(blockPolicy(ipBlockerConfig), ipBlockerConfig.LogFilePath, database.IPBlockStore{}, firewall)
+	if err != nil {
+		return err
+	}
+
+	ipBlocker.OnBlock = func(ip string, until time.Time) {
+		var blockedUntil interface{}
+		if !until.IsZero() {
+			blockedUntil = until.UTC()
+		}
+		events.Publish(events.AuthIPBlocked, 0, gin.H{"ip": ip, "blocked_until": blockedUntil})
+	}
+
+	// Konfiguratsiya va databasedagi ruxsat/taqiq ro'yxatlarini yuklash
+	return reloadAccessLists()
+}
+
+// blockPolicy - Konfiguratsiyadan bloklash siyosatini tuzish. ban_durations bo'sh bo'lsa har doim block_duration ishlatiladi.
+func blockPolicy(cfg config.IPBlockerConfig) security.BlockPolicy {
+	banDurations := cfg.BanDurations
+	if len(banDurations) == 0 {
+		banDurations = []int{cfg.BlockDuration}
+	}
+
+	policy := security.BlockPolicy{
+		MaxAttempts:       cfg.MaxAttempts,
+		Window:            time.Duration(cfg.FailureWindow) * time.Minute,
+		OffenseTTL:        time.Duration(cfg.OffenseTTL) * time.Hour,
+		SubnetMaxAttempts: cfg.Subnet.MaxAttempts,
+		IPv4Prefix:        cfg.Subnet.IPv4Prefix,
+		IPv6Prefix:        cfg.Subnet.IPv6Prefix,
+	}
+	for _, minutes := range banDurations {
+		policy.BanDurations = append(policy.BanDurations, time.Duration(minutes)*time.Minute)
+	}
+	return policy
+}
 
-	return err
+// abortBlocked - Bloklangan IP manzildan kelgan so'rovni rad etish
+func abortBlocked(c *gin.Context, block security.Block) {
+	metrics.RecordAuthFailure("ip_blocked")
+	if block.Permanent() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "IP manzil muddatsiz bloklangan"})
+	} else {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": fmt.Sprintf("IP manzil bloklangan. Qolgan vaqt: %s", time.Until(block.ExpiresAt).Round(time.Second)),
+		})
+	}
+	c.Abort()
 }
 
 // TokenAuthMiddleware - API token autentifikatsiyasi uchun middleware
@@ -59,19 +126,41 @@ func TokenAuthMiddleware() gin.HandlerFunc {
 		// IP bloklash tizimi ishga tushirilgan bo'lsa, IP manzilni tekshirish
 		if ipBlocker != nil && config.Config.Security.IPBlocker.Enabled {
 			// IP manzil bloklangan bo'lsa, so'rovni rad etish
-			if ipBlocker.IsBlocked(clientIP) {
-				remainingTime := ipBlocker.GetRemainingBlockTime(clientIP)
-				c.JSON(http.StatusTooManyRequests, gin.H{
-					"error": fmt.Sprintf("IP manzil bloklangan. Qolgan vaqt: %s", remainingTime.Round(time.Second)),
-				})
-				c.Abort()
+			if block, blocked := ipBlocker.Lookup(clientIP); blocked {
+				abortBlocked(c, block)
 				return
 			}
 		}
 
 		// Authorization headerini tekshirish
 		authHeader := c.GetHeader("Authorization")
+
+		// Header bo'lmasa, web interfeys sessiyasi cookiesini tekshirish
+		if sessionToken, cookieErr := c.Cookie(SessionCookieName); authHeader == "" && cookieErr == nil && sessionToken != "" {
+			session, user, err := authenticateSession(sessionToken)
+			if err != nil && !errors.Is(err, errInvalidToken) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Sessiyani tekshirishda xatolik: %v", err)})
+				c.Abort()
+				return
+			}
+			if err != nil {
+				metrics.RecordAuthFailure("invalid_session")
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Sessiya topilmadi yoki muddati o'tgan"})
+				c.Abort()
+				return
+			}
+
+			c.Set(ActorContextKey, "user:"+user.Username)
+			c.Set(ScopesContextKey, auth.ScopesForRole(user.Role))
+			c.Set(SessionContextKey, session)
+			c.Set(UserContextKey, user)
+			c.Next()
+			return
+		}
+
 		if authHeader == "" {
+			metrics.RecordAuthFailure("missing_header")
+
 			// IP bloklash tizimi ishga tushirilgan bo'lsa, muvaffaqiyatsiz urinishni qayd qilish
 			if ipBlocker != nil && config.Config.Security.IPBlocker.Enabled {
 				ipBlocker.RecordFailedAttempt(clientIP, c.Request.UserAgent(), c.Request.URL.Path)
@@ -85,6 +174,8 @@ func TokenAuthMiddleware() gin.HandlerFunc {
 		// Bearer token formatini tekshirish
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
+			metrics.RecordAuthFailure("invalid_format")
+
 			// IP bloklash tizimi ishga tushirilgan bo'lsa, muvaffaqiyatsiz urinishni qayd qilish
 			if ipBlocker != nil && config.Config.Security.IPBlocker.Enabled {
 				ipBlocker.RecordFailedAttempt(clientIP, c.Request.UserAgent(), c.Request.URL.Path)
@@ -95,9 +186,28 @@ func TokenAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Tokenni tekshirish
-		token := parts[1]
-		if token != config.Config.API.Token {
+		// Tokenni tekshirish (konfiguratsiya tokeni yoki databasedagi API kaliti)
+		actor, scopes, key, err := authenticateToken(parts[1])
+		if errors.Is(err, errTokenUnverifiable) {
+			metrics.RecordAuthFailure("jwks_unavailable")
+
+			// Identity provider ishlamayotganda ham token tanlash urinishlari hisobga olinadi
+			if ipBlocker != nil && config.Config.Security.IPBlocker.Enabled {
+				ipBlocker.RecordFailedAttempt(clientIP, c.Request.UserAgent(), c.Request.URL.Path)
+			}
+
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Tokenni hozir tekshirib bo'lmaydi, keyinroq qayta urinib ko'ring"})
+			c.Abort()
+			return
+		}
+		if err != nil && !errors.Is(err, errInvalidToken) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Tokenni tekshirishda xatolik: %v", err)})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			metrics.RecordAuthFailure("invalid_token")
+
 			// IP bloklash tizimi ishga tushirilgan bo'lsa, muvaffaqiyatsiz urinishni qayd qilish
 			if ipBlocker != nil && config.Config.Security.IPBlocker.Enabled {
 				ipBlocker.RecordFailedAttempt(clientIP, c.Request.UserAgent(), c.Request.URL.Path)
@@ -113,10 +223,51 @@ func TokenAuthMiddleware() gin.HandlerFunc {
 			ipBlocker.ResetFailedAttempts(clientIP)
 		}
 
+		c.Set(ActorContextKey, actor)
+		c.Set(ScopesContextKey, scopes)
+		if key != nil {
+			c.Set(APIKeyContextKey, key)
+		}
+		c.Next()
+	}
+}
+
+// MetricsAuthMiddleware - /metrics uchun alohida token tekshiruvi (token sozlanmagan bo'lsa ochiq)
+func MetricsAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := config.Config.Metrics.Token
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+token)) != 1 {
+			metrics.RecordAuthFailure("metrics_token")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Noto'g'ri metrics token"})
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
 
+// auditActor - So'rov egasini audit jurnali uchun olish
+func auditActor(c *gin.Context) string {
+	if actor := c.GetString(ActorContextKey); actor != "" {
+		return actor
+	}
+	return "unknown"
+}
+
+// blockedIPCount - Bloklangan IP manzillar soni (metrikalar uchun)
+func blockedIPCount() int {
+	if ipBlocker == nil {
+		return 0
+	}
+	return ipBlocker.BlockedCount()
+}
+
 // SetupRouter - API routerini sozlash
 func SetupRouter() *gin.Engine {
 	// Debug rejimini tekshirish
@@ -126,24 +277,96 @@ func SetupRouter() *gin.Engine {
 
 	r := gin.Default()
 
+	// Client IP manzili faqat ishonchli proxylar yuborgan sarlavhadan olinadi, aks holda ulanish manzili ishlatiladi.
+	// IP bloklash, audit va loglar c.ClientIP() orqali shu manzilni ko'radi.
+	if err := r.SetTrustedProxies(config.Config.Security.TrustedProxies); err != nil {
+		log.Printf("security.trusted_proxies ni o'qishda xatolik: %v", err)
+	}
+	if header := config.Config.Security.RealIPHeader; header != "" {
+		r.RemoteIPHeaders = []string{header}
+	}
+
+	r.Use(metrics.Middleware())
+
+	// Prometheus metrikalari
+	if config.Config.Metrics.Enabled {
+		var blocked func() int
+		if config.Config.Security.IPBlocker.Enabled {
+			blocked = blockedIPCount
+		}
+		if err := metrics.Register(metrics.NewCollector(wireguard.Current, blocked)); err != nil {
+			log.Printf("Metrikalar collectorini ro'yxatga olishda xatolik: %v", err)
+		}
+		r.GET("/metrics", MetricsAuthMiddleware(), gin.WrapH(metrics.Handler()))
+	}
+
+	// Web interfeysga kirish (tokensiz)
+	r.POST("/api/auth/login", LoginRateLimitMiddleware(), LoginHandler)
+
 	// API routerlari
 	api := r.Group("/api")
 	api.Use(TokenAuthMiddleware()) // Barcha API so'rovlari uchun token autentifikatsiyasi
 
-	// Client API endpointlari
-	api.POST("/client", CreateClientHandler)
-	api.GET("/clients", GetAllClientsHandler)
-	api.GET("/client/:id", GetClientHandler)
-	api.DELETE("/client/:id", DeleteClientHandler)
-	api.GET("/client/:id/lifetime", GetClientLifetimeHandler)
-	api.PUT("/client/:id/lifetime", UpdateClientLifetimeHandler)
-	api.GET("/client/:id/traffic", GetClientTrafficHandler)
-	api.GET("/clients/traffic", GetAllClientsTrafficHandler)
-
-	// Server holati API endpointi
-	api.GET("/server/status", GetServerStatusHandler)
+	// Joriy sessiya va ikki bosqichli autentifikatsiya (faqat web interfeys foydalanuvchilari)
+	session := api.Group("/auth", RequireSession())
+	session.POST("/logout", LogoutHandler)
+	session.GET("/me", GetCurrentUserHandler)
+	session.POST("/totp/setup", SetupTOTPHandler)
+	session.POST("/totp/enable", EnableTOTPHandler)
+	session.POST("/totp/disable", DisableTOTPHandler)
+
+	// Client ma'lumotlarini o'qish
+	clientsRead := api.Group("", RequireScope(auth.ScopeClientsRead), RateLimitMiddleware(RateLimitRead))
+	clientsRead.GET("/clients", GetAllClientsHandler)
+	clientsRead.GET("/client/:id", GetClientHandler)
+	clientsRead.GET("/client/:id/lifetime", GetClientLifetimeHandler)
+	clientsRead.GET("/client/:id/traffic", GetClientTrafficHandler)
+	clientsRead.GET("/client/:id/traffic/history", GetClientTrafficHistoryHandler)
+	clientsRead.GET("/client/:id/sessions", GetClientSessionsHandler)
+	clientsRead.GET("/clients/traffic", GetAllClientsTrafficHandler)
+
+	// Clientlarni o'zgartirish
+	clientsWrite := api.Group("", RequireScope(auth.ScopeClientsWrite))
+	// IP manzillar poolini himoya qilish uchun alohida chegara; write tokeni client_create rad etsa sarflanmaydi
+	clientsWrite.POST("/client", RateLimitMiddleware(RateLimitWrite, RateLimitClientCreate), CreateClientHandler)
+	clientsWrite = clientsWrite.Group("", RateLimitMiddleware(RateLimitWrite))
+	clientsWrite.DELETE("/client/:id", DeleteClientHandler)
+	clientsWrite.PUT("/client/:id/lifetime", UpdateClientLifetimeHandler)
+	clientsWrite.PUT("/client/:id/quota", UpdateClientQuotaHandler)
+	clientsWrite.POST("/client/:id/suspend", SuspendClientHandler)
+	clientsWrite.POST("/client/:id/resume", ResumeClientHandler)
+
+	// Server holati API endpointlari
+	serverRead := api.Group("", RequireScope(auth.ScopeServerRead), RateLimitMiddleware(RateLimitRead))
+	serverRead.GET("/server/status", GetServerStatusHandler)
+	serverRead.GET("/server/drift", GetServerDriftHandler)
+	serverRead.GET("/pools", GetPoolsHandler)
+	serverRead.GET("/events", EventsHandler) // Hodisalar oqimi (Server-Sent Events)
+
+	// Har qanday kalit bilan ochiq
 	api.GET("/health", GetHealthHandler)
 
+	// Administrator endpointlari: audit jurnali va API kalitlari
+	admin := api.Group("", RequireScope(auth.ScopeAdmin), RateLimitMiddleware(RateLimitAdmin))
+	admin.GET("/audit", GetAuditLogsHandler)
+	admin.POST("/keys", CreateAPIKeyHandler)
+	admin.GET("/keys", GetAPIKeysHandler)
+	admin.PUT("/keys/:id", UpdateAPIKeyHandler)
+	admin.DELETE("/keys/:id", RevokeAPIKeyHandler)
+	admin.POST("/users", CreateUserHandler)
+	admin.GET("/users", GetUsersHandler)
+	admin.PUT("/users/:id", UpdateUserHandler)
+	admin.DELETE("/users/:id", DeleteUserHandler)
+
+	// IP bloklash tizimini boshqarish
+	blocklist := admin.Group("/security", RequireIPBlocker())
+	blocklist.GET("/blocked", GetBlockedIPsHandler)
+	blocklist.POST("/blocked", BlockIPHandler)
+	blocklist.DELETE("/blocked/*ip", UnblockIPHandler)
+	blocklist.GET("/access", GetAccessListsHandler)
+	blocklist.POST("/access", CreateAccessRuleHandler)
+	blocklist.DELETE("/access/:id", DeleteAccessRuleHandler)
+
 	return r
 }
 
@@ -154,6 +377,8 @@ func CreateClientHandler(c *gin.Context) {
 		Description string `json:"description"`
 		LifeTime    int    `json:"life_time"`
 		Type        string `json:"type"`
+		QuotaBytes  int64  `json:"quota_bytes"`  // Baytlarda, 0 = cheksiz
+		QuotaPeriod string `json:"quota_period"` // "" yoki "monthly"
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -161,6 +386,12 @@ func CreateClientHandler(c *gin.Context) {
 		return
 	}
 
+	// Kvotani tekshirish
+	if err := validateQuota(req.QuotaBytes, req.QuotaPeriod); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Type ni tekshirish
 	if req.Type != "" && req.Type != "normal" && req.Type != "vip" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Type faqat 'normal' yoki 'vip' bo'lishi mumkin"})
@@ -180,13 +411,6 @@ func CreateClientHandler(c *gin.Context) {
 		clientType = models.ClientTypeNormal
 	}
 
-	// Ishlatilgan IP manzillarni olish
-	usedIPs, err := database.GetUsedIPAddresses("")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Ishlatilgan IP manzillarni olishda xatolik: %v", err)})
-		return
-	}
-
 	// Server public key ni o'qish
 	serverPublicKey, err := wireguard.GetServerPublicKey()
 	if err != nil {
@@ -208,19 +432,11 @@ func CreateClientHandler(c *gin.Context) {
 		return
 	}
 
-	// Client IP manzilini yaratish
-	clientIP, err := wireguard.FindAvailableIP(clientType, usedIPs)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Bo'sh IP manzil topilmadi: " + err.Error()})
-		return
-	}
-
 	// Client obyektini yaratish
 	client := &models.WireguardClient{
 		PublicKey:    clientPublicKey,
 		PrivateKey:   clientPrivateKey,
 		PresharedKey: presharedKey,
-		Address:      clientIP,
 		Description:  req.Description,
 		Active:       true,
 		Type:         clientType,
@@ -228,6 +444,14 @@ func CreateClientHandler(c *gin.Context) {
 		Endpoint:     fmt.Sprintf("%s:%d", config.Config.Server.IP, config.Config.Server.Port),
 		DNS:          config.Config.Wireguard.DNS,
 		AllowedIPs:   config.Config.Wireguard.AllowedIPs,
+		QuotaBytes:   req.QuotaBytes,
+		QuotaPeriod:  models.QuotaPeriod(req.QuotaPeriod),
+	}
+
+	// Oylik kvota joriy davrdan boshlanadi
+	if client.QuotaPeriod == models.QuotaPeriodMonthly {
+		periodStart := monitor.PeriodStart(time.Now())
+		client.PeriodStartedAt = &periodStart
 	}
 
 	// ExpiresAt ni hisoblash
@@ -236,21 +460,22 @@ func CreateClientHandler(c *gin.Context) {
 		client.ExpiresAt = &expiresAt
 	}
 
-	// Serverda client konfiguratsiyasini saqlash
-	err = wireguard.AddPeerToServer(clientPublicKey, clientIP, presharedKey)
+	// Pooldan IP manzil ajratib, clientni databasega saqlash va peerni qo'shish.
+	// Qaysi qadamda xatolik bo'lmasin, ikkinchisi ham bekor qilinadi
+	err = database.ProvisionClient(client, wireguard.Current)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		if errors.Is(err, ipam.ErrPoolExhausted) {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{"error": fmt.Sprintf("Clientni yaratishda xatolik: %v", err)})
 		return
 	}
 
-	// Clientni databasega saqlash
-	if err := database.SaveClient(client); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Clientni saqlashda xatolik: %v", err)})
-		return
-	}
+	database.RecordAudit(auditActor(c), c.ClientIP(), database.AuditClientCreate, client.ID, nil, client)
 
 	// Client konfiguratsiyasini yaratish
-	configText, _ := wireguard.CreateClientConfig(clientPrivateKey, presharedKey, clientIP, serverPublicKey)
+	configText, _ := wireguard.CreateClientConfig(clientPrivateKey, presharedKey, strings.Join(client.Addresses(), ", "), serverPublicKey)
 
 	// Natijani qaytarish
 	c.JSON(http.StatusOK, gin.H{
@@ -267,6 +492,12 @@ func GetAllClientsHandler(c *gin.Context) {
 		return
 	}
 
+	if !canReadClientSecrets(c) {
+		for i := range clients {
+			hideClientSecrets(&clients[i])
+		}
+	}
+
 	c.JSON(http.StatusOK, clients)
 }
 
@@ -280,9 +511,26 @@ func GetClientHandler(c *gin.Context) {
 		return
 	}
 
+	if !canReadClientSecrets(c) {
+		hideClientSecrets(&client)
+	}
+
 	c.JSON(http.StatusOK, client)
 }
 
+// canReadClientSecrets - Client kalitlari faqat clients:write (yoki admin) ruxsatiga ega so'rovlarga ko'rsatiladi.
+// clients:read ruxsati (masalan viewer roli) faqat kalitsiz ma'lumotlarni ko'radi.
+func canReadClientSecrets(c *gin.Context) bool {
+	return auth.HasScope(c.GetStringSlice(ScopesContextKey), auth.ScopeClientsWrite)
+}
+
+// hideClientSecrets - Client private key, preshared key va konfiguratsiya matnini javobdan olib tashlash
+func hideClientSecrets(client *models.WireguardClient) {
+	client.PrivateKey = ""
+	client.PresharedKey = ""
+	client.ConfigText = ""
+}
+
 // DeleteClientHandler - Clientni o'chirish uchun handler
 func DeleteClientHandler(c *gin.Context) {
 	id := c.Param("id")
@@ -293,17 +541,13 @@ func DeleteClientHandler(c *gin.Context) {
 		return
 	}
 
-	// Wireguard konfiguratsiyasidan peer ni o'chirish
-	if err := wireguard.RemovePeerFromServer(client.PublicKey); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Peerni o'chirishda xatolik: " + err.Error()})
+	// Peerni interfacedan va clientni databasedan to'liq o'chirish (hard delete)
+	if err := database.DeprovisionClient(&client, events.ClientDeleted, wireguard.Current); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Clientni o'chirishda xatolik: " + err.Error()})
 		return
 	}
 
-	// Databasedan to'liq o'chirish (hard delete)
-	if err := database.DB.Unscoped().Delete(&client).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Clientni databasedan o'chirishda xatolik: " + err.Error()})
-		return
-	}
+	database.RecordAudit(auditActor(c), c.ClientIP(), database.AuditClientDelete, client.ID, &client, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Client muvaffaqiyatli o'chirildi"})
 }
@@ -367,14 +611,18 @@ func UpdateClientLifetimeHandler(c *gin.Context) {
 	}
 
 	// Clientni yangilash
+	previous := client
 	client.LifeTime = request.LifeTime
 	client.ExpiresAt = expiresAt
+	client.ExpiryNotifiedAt = nil // Yangi muddat uchun qayta ogohlantiriladi
 
-	if err := database.UpdateClient(&client); err != nil {
+	if err := database.UpdateClient(&client, "life_time", "expires_at", "expiry_notified_at"); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Clientni yangilashda xatolik: " + err.Error()})
 		return
 	}
 
+	database.RecordAudit(auditActor(c), c.ClientIP(), database.AuditLifetimeUpdate, client.ID, &previous, &client)
+
 	// Qolgan vaqtni hisoblash
 	var remainingTime int64 = 0
 	if client.ExpiresAt != nil {
@@ -395,6 +643,169 @@ func UpdateClientLifetimeHandler(c *gin.Context) {
 	})
 }
 
+// UpdateClientQuotaHandler - Client traffic kvotasini yangilash uchun handler
+func UpdateClientQuotaHandler(c *gin.Context) {
+	id := c.Param("id")
+
+	var client models.WireguardClient
+	if err := database.DB.First(&client, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client topilmadi"})
+		return
+	}
+
+	var request struct {
+		QuotaBytes  int64  `json:"quota_bytes"`  // Baytlarda, 0 = cheksiz
+		QuotaPeriod string `json:"quota_period"` // "" yoki "monthly"
+		ResetUsage  bool   `json:"reset_usage"`  // Ishlatilgan trafficni nolga tushirish
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri so'rov formati"})
+		return
+	}
+
+	if err := validateQuota(request.QuotaBytes, request.QuotaPeriod); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previous := client
+	client.QuotaBytes = request.QuotaBytes
+	client.QuotaPeriod = models.QuotaPeriod(request.QuotaPeriod)
+
+	// used_bytes ni traffic monitor yozadi, shuning uchun u faqat nolga tushirilganda yangilanadi
+	columns := []string{"quota_bytes", "quota_period", "period_started_at"}
+
+	// Davr turi o'zgarganda yoki so'ralganda hisob yangidan boshlanadi
+	if request.ResetUsage {
+		client.UsedBytes = 0
+		columns = append(columns, "used_bytes")
+	}
+	if client.QuotaPeriod == models.QuotaPeriodMonthly && (previous.QuotaPeriod != models.QuotaPeriodMonthly || request.ResetUsage) {
+		periodStart := monitor.PeriodStart(time.Now())
+		client.PeriodStartedAt = &periodStart
+	} else if client.QuotaPeriod != models.QuotaPeriodMonthly {
+		client.PeriodStartedAt = nil
+	}
+
+	if err := database.UpdateClient(&client, columns...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Clientni yangilashda xatolik: " + err.Error()})
+		return
+	}
+
+	// Kvota tufayli to'xtatilgan client endi limitdan oshmasa, qayta yoqiladi
+	if !client.Active && client.SuspendReason == models.SuspendReasonQuota && !client.QuotaExceeded() {
+		err := database.ResumeClient(&client, wireguard.Current)
+		if err != nil {
+			database.RecordAudit(auditActor(c), c.ClientIP(), database.AuditQuotaUpdate, client.ID, &previous, &client)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Kvota yangilandi, lekin clientni qayta yoqishda xatolik: %v", err)})
+			return
+		}
+	}
+
+	database.RecordAudit(auditActor(c), c.ClientIP(), database.AuditQuotaUpdate, client.ID, &previous, &client)
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":                client.ID,
+		"active":            client.Active,
+		"quota_bytes":       client.QuotaBytes,
+		"quota_period":      client.QuotaPeriod,
+		"used_bytes":        client.UsedBytes,
+		"period_started_at": client.PeriodStartedAt,
+		"message":           "Client kvotasi muvaffaqiyatli yangilandi",
+	})
+}
+
+// validateQuota - Kvota qiymatlarini tekshirish
+func validateQuota(quotaBytes int64, quotaPeriod string) error {
+	if quotaBytes < 0 {
+		return fmt.Errorf("quota_bytes manfiy bo'lishi mumkin emas")
+	}
+	if quotaPeriod != string(models.QuotaPeriodNone) && quotaPeriod != string(models.QuotaPeriodMonthly) {
+		return fmt.Errorf("quota_period faqat bo'sh yoki 'monthly' bo'lishi mumkin")
+	}
+	return nil
+}
+
+// SuspendClientHandler - Clientni o'chirmasdan to'xtatib qo'yish uchun handler
+func SuspendClientHandler(c *gin.Context) {
+	id := c.Param("id")
+
+	var client models.WireguardClient
+	if err := database.DB.First(&client, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client topilmadi"})
+		return
+	}
+
+	// Sabab ixtiyoriy
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	// Chunked so'rovlarda ContentLength -1 bo'ladi, shuning uchun body mavjudligi bo'yicha tekshiriladi
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri so'rov formati"})
+			return
+		}
+	}
+
+	if !client.Active {
+		c.JSON(http.StatusConflict, gin.H{"error": "Client allaqachon to'xtatilgan"})
+		return
+	}
+
+	previous := client
+	if err := database.SuspendClient(&client, request.Reason, wireguard.Current); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Clientni to'xtatishda xatolik: " + err.Error()})
+		return
+	}
+
+	database.RecordAudit(auditActor(c), c.ClientIP(), database.AuditClientSuspend, client.ID, &previous, &client)
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":             client.ID,
+		"active":         client.Active,
+		"suspended_at":   client.SuspendedAt,
+		"suspend_reason": client.SuspendReason,
+		"message":        "Client muvaffaqiyatli to'xtatildi",
+	})
+}
+
+// ResumeClientHandler - To'xtatilgan clientni qayta yoqish uchun handler
+func ResumeClientHandler(c *gin.Context) {
+	id := c.Param("id")
+
+	var client models.WireguardClient
+	if err := database.DB.First(&client, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client topilmadi"})
+		return
+	}
+
+	if client.Active {
+		c.JSON(http.StatusConflict, gin.H{"error": "Client to'xtatilmagan"})
+		return
+	}
+
+	// Kvotasi tugagan client monitor tomonidan darhol yana to'xtatiladi
+	if client.QuotaExceeded() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Client kvotasi tugagan, avval kvotani yangilang"})
+		return
+	}
+
+	previous := client
+	if err := database.ResumeClient(&client, wireguard.Current); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Clientni qayta yoqishda xatolik: " + err.Error()})
+		return
+	}
+
+	database.RecordAudit(auditActor(c), c.ClientIP(), database.AuditClientResume, client.ID, &previous, &client)
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":      client.ID,
+		"active":  client.Active,
+		"message": "Client muvaffaqiyatli qayta yoqildi",
+	})
+}
+
 // GetClientTrafficHandler - Client traffic ma'lumotlarini olish
 func GetClientTrafficHandler(c *gin.Context) {
 	// Client ID ni olish
@@ -418,11 +829,34 @@ func GetClientTrafficHandler(c *gin.Context) {
 		return
 	}
 
-	// Client traffic ma'lumotlarini olish
-	traffic, err := wireguard.GetClientTraffic(client.PublicKey)
+	// Client traffic ma'lumotlarini olish.
+	// To'xtatilgan clientning peeri interfaceda bo'lmaydi, bunday holatda nol qiymatlar qaytariladi
+	traffic, err := wireguard.GetClientTraffic(wireguard.Current, client.PublicKey)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Traffic ma'lumotlarini olishda xatolik: %v", err)})
-		return
+		if client.Active {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Traffic ma'lumotlarini olishda xatolik: %v", err)})
+			return
+		}
+		traffic = &wireguard.ClientTraffic{
+			PublicKey:              client.PublicKey,
+			BytesReceivedFormatted: formatBytes(0),
+			BytesSentFormatted:     formatBytes(0),
+		}
+	}
+
+	// Qolgan kvotani hisoblash (-1 = cheksiz)
+	var remainingBytes int64 = -1
+	if client.QuotaBytes > 0 {
+		remainingBytes = client.QuotaBytes - client.UsedBytes
+		if remainingBytes < 0 {
+			remainingBytes = 0
+		}
+	}
+
+	var periodResetsAt *time.Time
+	if client.QuotaPeriod == models.QuotaPeriodMonthly {
+		next := monitor.NextPeriodStart(time.Now())
+		periodResetsAt = &next
 	}
 
 	// Natijani qaytarish
@@ -437,9 +871,148 @@ func GetClientTrafficHandler(c *gin.Context) {
 		"bytes_received_formatted": traffic.BytesReceivedFormatted,
 		"bytes_sent_formatted":     traffic.BytesSentFormatted,
 		"allowed_ips":              traffic.AllowedIPs,
+		"quota_bytes":              client.QuotaBytes,
+		"quota_period":             client.QuotaPeriod,
+		"used_bytes":               client.UsedBytes,
+		"remaining_bytes":          remainingBytes,
+		"period_started_at":        client.PeriodStartedAt,
+		"period_resets_at":         periodResetsAt,
 	})
 }
 
+// GetClientTrafficHistoryHandler - Client traffic tarixini vaqt oralig'i bo'yicha olish
+func GetClientTrafficHistoryHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri ID formati"})
+		return
+	}
+
+	client, err := database.GetClientByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client topilmadi"})
+		return
+	}
+
+	bucket := c.DefaultQuery("bucket", database.BucketDay)
+
+	// Standart oraliq bucket turiga bog'liq
+	to := time.Now()
+	var from time.Time
+	switch bucket {
+	case database.BucketHour:
+		from = to.Add(-24 * time.Hour)
+	case database.BucketDay:
+		from = to.AddDate(0, 0, -30)
+	case database.BucketMonth:
+		from = to.AddDate(-1, 0, 0)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bucket faqat 'hour', 'day' yoki 'month' bo'lishi mumkin"})
+		return
+	}
+
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from RFC3339 formatida bo'lishi kerak"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to RFC3339 formatida bo'lishi kerak"})
+			return
+		}
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from vaqti to vaqtidan oldin bo'lishi kerak"})
+		return
+	}
+
+	history, err := database.GetTrafficHistory(client.ID, from, to, bucket)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Traffic tarixini olishda xatolik: %v", err)})
+		return
+	}
+
+	// Oraliqdagi jami traffic
+	var totalReceived, totalSent int64
+	for _, item := range history {
+		totalReceived += item.BytesReceived
+		totalSent += item.BytesSent
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"client_id":      client.ID,
+		"from":           from.UTC(),
+		"to":             to.UTC(),
+		"bucket":         bucket,
+		"history":        history,
+		"bytes_received": totalReceived,
+		"bytes_sent":     totalSent,
+		"total_bytes":    totalReceived + totalSent,
+	})
+}
+
+// GetClientSessionsHandler - Client ulanish sessiyalarini olish
+func GetClientSessionsHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri ID formati"})
+		return
+	}
+
+	client, err := database.GetClientByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client topilmadi"})
+		return
+	}
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessions, total, err := database.GetClientSessions(client.ID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Sessiyalarni olishda xatolik: %v", err)})
+		return
+	}
+
+	// Oxirgi handshake eskirmagan bo'lsa, client onlayn hisoblanadi
+	var lastConnected *time.Time
+	online := false
+	if !client.LastConnected.IsZero() {
+		lastConnected = &client.LastConnected
+		online = time.Since(client.LastConnected) <= database.SessionTimeout
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"client_id":      client.ID,
+		"online":         online,
+		"last_connected": lastConnected,
+		"sessions":       sessions,
+		"total":          total,
+		"limit":          limit,
+		"offset":         offset,
+	})
+}
+
+// parsePagination - limit va offset query parametrlarini o'qish
+func parsePagination(c *gin.Context) (int, int, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		return 0, 0, fmt.Errorf("limit 1 dan 500 gacha bo'lishi kerak")
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("offset manfiy bo'lmagan son bo'lishi kerak")
+	}
+
+	return limit, offset, nil
+}
+
 // GetAllClientsTrafficHandler - Barcha clientlar traffic ma'lumotlarini olish
 func GetAllClientsTrafficHandler(c *gin.Context) {
 	// Barcha clientlarni databasedan olish
@@ -450,7 +1023,7 @@ func GetAllClientsTrafficHandler(c *gin.Context) {
 	}
 
 	// Barcha clientlar traffic ma'lumotlarini olish
-	trafficList, err := wireguard.GetAllClientsTraffic()
+	trafficList, err := wireguard.GetAllClientsTraffic(wireguard.Current)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Traffic ma'lumotlarini olishda xatolik: %v", err)})
 		return
@@ -481,7 +1054,7 @@ func GetAllClientsTrafficHandler(c *gin.Context) {
 				"bytes_sent":               int64(0),
 				"bytes_received_formatted": "0 B",
 				"bytes_sent_formatted":     "0 B",
-				"allowed_ips":              client.Address,
+				"allowed_ips":              strings.Join(wireguard.PeerFromClient(&client).AllowedIPs, ","),
 			})
 		} else {
 			// Traffic ma'lumotlari bilan client ma'lumotlarini birlashtirish
@@ -521,7 +1094,7 @@ func formatBytes(bytes int64) string {
 // GetServerStatusHandler - Server holatini olish uchun handler
 func GetServerStatusHandler(c *gin.Context) {
 	// Server holatini olish
-	status, err := wireguard.GetServerStatus()
+	status, err := wireguard.GetServerStatus(wireguard.Current)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Server holatini olishda xatolik: %v", err)})
 		return
@@ -556,6 +1129,91 @@ func GetServerStatusHandler(c *gin.Context) {
 	})
 }
 
+// GetServerDriftHandler - Database va interface o'rtasidagi farqlarni ko'rsatish
+func GetServerDriftHandler(c *gin.Context) {
+	// Hozirgi holatni tekshirish (tuzatishsiz)
+	report, err := reconcile.Check(wireguard.Current)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Drift tekshiruvida xatolik: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"report":      report,
+		"last_run":    reconcile.LastReport(),
+		"auto_repair": config.Config.Reconciler.AutoRepair,
+	})
+}
+
+// GetPoolsHandler - IP manzillar poollarining bandlik statistikasini olish
+func GetPoolsHandler(c *gin.Context) {
+	usage, err := database.GetPoolUsage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Pool statistikasini olishda xatolik: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"pools":            usage,
+		"quarantine_hours": int(database.QuarantineDuration().Hours()),
+	})
+}
+
+// GetAuditLogsHandler - Audit jurnalini filtrlar bilan olish
+func GetAuditLogsHandler(c *gin.Context) {
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := database.AuditFilter{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	if value := c.Query("client_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri client_id formati"})
+			return
+		}
+		clientID := uint(id)
+		filter.ClientID = &clientID
+	}
+	if value := c.Query("from"); value != "" {
+		from, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from RFC3339 formatida bo'lishi kerak"})
+			return
+		}
+		filter.From = &from
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to RFC3339 formatida bo'lishi kerak"})
+			return
+		}
+		filter.To = &to
+	}
+
+	entries, total, err := database.GetAuditLogs(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Audit jurnalini olishda xatolik: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
 func GetHealthHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
@@ -1,13 +1,128 @@
 package api
 
 import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
+	"wireguard-vpn-client-creater/pkg/auth"
 	"wireguard-vpn-client-creater/pkg/config"
+	"wireguard-vpn-client-creater/pkg/database"
+	"wireguard-vpn-client-creater/pkg/models"
 )
 
+// errInvalidToken - Token konfiguratsiyadagi tokenga ham, API kalitlariga ham, JWT tekshiruviga ham mos kelmadi
+var errInvalidToken = errors.New("noto'g'ri token")
+
+// errTokenUnverifiable - Token JWT ko'rinishida, lekin identity provider kalitlari hali yuklanmagani uchun tekshirib bo'lmadi
+var errTokenUnverifiable = errors.New("tokenni hozir tekshirib bo'lmaydi")
+
+// jwtVerifier - Identity provider tokenlarini tekshiruvchi (jwt.enabled bo'lmasa nil)
+var jwtVerifier *auth.JWTVerifier
+
+// InitJWTVerifier - Tashqi identity provider JWT larini qabul qilishni sozlash
+func InitJWTVerifier() error {
+	verifier, err := auth.NewJWTVerifier(config.Config.Auth.JWT)
+	if err != nil {
+		return err
+	}
+
+	jwtVerifier = verifier
+	return nil
+}
+
+// authenticateToken - Bearer tokenni tekshirib, so'rov egasi va uning ruxsatlarini aniqlash.
+// Konfiguratsiyadagi token bootstrap administrator kaliti hisoblanadi. API kaliti bo'lsa u ham qaytariladi.
+func authenticateToken(token string) (string, []string, *models.APIKey, error) {
+	configToken := config.Config.API.Token
+	if configToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(configToken)) == 1 {
+		return "token:config", []string{auth.ScopeAdmin}, nil, nil
+	}
+
+	if !strings.HasPrefix(token, database.APIKeyPrefix) {
+		actor, scopes, err := authenticateJWT(token)
+		return actor, scopes, nil, err
+	}
+
+	key, err := database.GetActiveAPIKey(token)
+	if errors.Is(err, database.ErrAPIKeyNotFound) {
+		return "", nil, nil, errInvalidToken
+	}
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	database.TouchAPIKey(key)
+	return fmt.Sprintf("apikey:%d", key.ID), auth.SplitScopes(string(key.Scopes)), key, nil
+}
+
+// authenticateJWT - Identity provider imzolagan tokenni tekshirish. So'rov egasi sifatida "sub" claim yoziladi.
+func authenticateJWT(token string) (string, []string, error) {
+	if jwtVerifier == nil || !auth.LooksLikeJWT(token) {
+		return "", nil, errInvalidToken
+	}
+
+	identity, err := jwtVerifier.Verify(token)
+	if errors.Is(err, auth.ErrInvalidJWT) {
+		if config.Config.Server.Debug {
+			log.Printf("JWT rad etildi: %v", err)
+		}
+		return "", nil, errInvalidToken
+	}
+	if errors.Is(err, auth.ErrJWKSUnavailable) {
+		log.Printf("JWT tekshirilmadi: %v", err)
+		return "", nil, errTokenUnverifiable
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	return "jwt:" + identity.Subject, identity.Scopes, nil
+}
+
+// SessionCookieName - Web interfeys sessiyasi saqlanadigan cookie nomi
+const SessionCookieName = "wg_session"
+
+// authenticateSession - Sessiya cookiesi bo'yicha foydalanuvchini aniqlash
+func authenticateSession(token string) (*models.UserSession, *models.AdminUser, error) {
+	session, user, err := database.GetSessionUser(token)
+	if errors.Is(err, database.ErrSessionNotFound) {
+		return nil, nil, errInvalidToken
+	}
+	return session, user, err
+}
+
+// RequireScope - So'rov egasida kerakli ruxsat borligini tekshiruvchi middleware
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.HasScope(c.GetStringSlice(ScopesContextKey), scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Ruxsat yo'q: '%s' scope talab qilinadi", scope)})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSession - Faqat web interfeys sessiyasi orqali kirgan foydalanuvchilar uchun middleware
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get(UserContextKey); !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Bu amal faqat login orqali kirgan foydalanuvchilar uchun"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // AuthMiddleware - API uchun token-based autentifikatsiya middleware
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wireguard-vpn-client-creater/pkg/config"
+	"wireguard-vpn-client-creater/pkg/metrics"
+	"wireguard-vpn-client-creater/pkg/models"
+	"wireguard-vpn-client-creater/pkg/security"
+)
+
+// So'rovlar chastotasi cheklanadigan route guruhlari
+const (
+	RateLimitRead         = "read"
+	RateLimitWrite        = "write"
+	RateLimitAdmin        = "admin"
+	RateLimitClientCreate = "client_create"
+	RateLimitLogin        = "login"
+)
+
+// defaultLoginRateLimit - Konfiguratsiyada login chegarasi ko'rsatilmaganda ishlatiladigan chegara
+var defaultLoginRateLimit = security.RateLimit{PerMinute: 10, Burst: 5}
+
+// rateLimitRemainingKey - Joriy so'rovga qo'llangan chegaralar ichidagi eng kam qolgan tokenlar (gin context kaliti)
+const rateLimitRemainingKey = "rate_limit_remaining"
+
+// rateLimiter - Global so'rovlar chastotasi cheklovchisi (InitRateLimiter chaqirilmaguncha nil)
+var rateLimiter *security.RateLimiter
+
+// InitRateLimiter - So'rovlar chastotasi cheklovchisini ishga tushirish.
+// Login chegarasi rate_limit.enabled dan qat'i nazar ishlagani uchun cheklovchi doim yaratiladi.
+func InitRateLimiter() {
+	rateLimiter = security.NewRateLimiter()
+}
+
+// RateLimitMiddleware - So'rov egasi bo'yicha guruhlar chegarasini tekshiruvchi middleware.
+// Bir nechta guruh berilsa (masalan write va client_create) token faqat barchasida yetarli bo'lsa olinadi.
+// Chegara oshsa 429 va Retry-After qaytariladi, RateLimit-* sarlavhalari eng qattiq chegarani ko'rsatadi.
+func RateLimitMiddleware(groups ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.Config.Security.RateLimit.Enabled {
+			c.Next()
+			return
+		}
+
+		limits := make([]security.RateLimit, len(groups))
+		keys := make([]string, len(groups))
+		for i, group := range groups {
+			limits[i] = rateLimitFor(c, group)
+			keys[i] = auditActor(c) + "|" + group
+		}
+
+		if !enforceRateLimit(c, groups, keys, limits) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// LoginRateLimitMiddleware - Login urinishlarini IP manzil bo'yicha cheklovchi middleware.
+// Parol tanlashga qarshi himoya sifatida rate_limit.enabled va IP bloker holatidan qat'i nazar ishlaydi.
+func LoginRateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule := config.Config.Security.RateLimit.Login
+		limit := security.RateLimit{PerMinute: rule.Rate, Burst: rule.Burst}
+		if rule.Rate == 0 {
+			limit = defaultLoginRateLimit
+		}
+
+		key := "ip:" + c.ClientIP() + "|" + RateLimitLogin
+		if !enforceRateLimit(c, []string{RateLimitLogin}, []string{key}, []security.RateLimit{limit}) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// enforceRateLimit - Guruhlar bucketlaridan token olish va eng qattiq chegara bo'yicha RateLimit-* sarlavhalarini o'rnatish.
+// Chegara oshsa 429 javobini yozib false qaytaradi.
+func enforceRateLimit(c *gin.Context, groups, keys []string, limits []security.RateLimit) bool {
+	if rateLimiter == nil {
+		return true
+	}
+
+	results := rateLimiter.AllowAll(keys, limits)
+
+	denied := -1
+	for i, result := range results {
+		if limits[i].Unlimited() {
+			continue
+		}
+
+		if previous, ok := c.Get(rateLimitRemainingKey); !ok || result.Remaining <= previous.(int) || !result.Allowed {
+			c.Set(rateLimitRemainingKey, result.Remaining)
+			header := c.Writer.Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+			header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", result.Limit, ceilSeconds(limits[i].Window())))
+		}
+
+		if !result.Allowed && (denied < 0 || result.RetryAfter > results[denied].RetryAfter) {
+			denied = i
+		}
+	}
+
+	if denied >= 0 {
+		metrics.RecordRateLimited(groups[denied])
+		retryAfter := ceilSeconds(results[denied].RetryAfter)
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       fmt.Sprintf("So'rovlar chegarasi oshib ketdi. %d soniyadan keyin qayta urinib ko'ring", retryAfter),
+			"retry_after": retryAfter,
+		})
+		c.Abort()
+		return false
+	}
+
+	return true
+}
+
+// rateLimitFor - Guruh chegarasi: konfiguratsiyadagi qiymat yoki API kalitining shu guruh uchun o'z chegarasi.
+// Kalit chegarasi faqat tezlikni almashtiradi, burst konfiguratsiyadan olinadi va tezlikdan oshmaydi.
+func rateLimitFor(c *gin.Context, group string) security.RateLimit {
+	cfg := config.Config.Security.RateLimit
+
+	var rule config.RateLimitRule
+	switch group {
+	case RateLimitRead:
+		rule = cfg.Read
+	case RateLimitWrite:
+		rule = cfg.Write
+	case RateLimitAdmin:
+		rule = cfg.Admin
+	case RateLimitClientCreate:
+		rule = cfg.ClientCreate
+	}
+	limit := security.RateLimit{PerMinute: rule.Rate, Burst: rule.Burst}
+
+	if value, ok := c.Get(APIKeyContextKey); ok {
+		key := value.(*models.APIKey)
+		var override int
+		switch group {
+		case RateLimitRead:
+			override = key.ReadLimit
+		case RateLimitWrite:
+			override = key.WriteLimit
+		case RateLimitAdmin:
+			override = key.AdminLimit
+		case RateLimitClientCreate:
+			override = key.ClientCreateLimit
+		}
+		if override > 0 {
+			limit.PerMinute = override
+			if limit.Burst <= 0 || limit.Burst > override {
+				limit.Burst = override
+			}
+		}
+	}
+
+	return limit
+}
+
+// ceilSeconds - Davomiylikni yuqoriga yaxlitlangan soniyalarga o'tkazish
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
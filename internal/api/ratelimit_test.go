@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"wireguard-vpn-client-creater/pkg/config"
+)
+
+func TestLoginRateLimitHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previousConfig := config.Config
+	t.Cleanup(func() { config.Config = previousConfig })
+
+	tests := []struct {
+		name       string
+		rule       config.RateLimitRule
+		wantLimit  string
+		wantPolicy string
+	}{
+		{"burst ko'rsatilmasa tezlikka teng", config.RateLimitRule{Rate: 600}, "600", "600;w=60"},
+		{"burst ko'rsatilgan", config.RateLimitRule{Rate: 60, Burst: 30}, "30", "30;w=30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			InitRateLimiter()
+			config.Config.Security.RateLimit.Login = tt.rule
+
+			router := gin.New()
+			router.POST("/login", LoginRateLimitMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/login", nil))
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, kutilgan %d", recorder.Code, http.StatusOK)
+			}
+			if got := recorder.Header().Get("RateLimit-Limit"); got != tt.wantLimit {
+				t.Errorf("RateLimit-Limit = %q, kutilgan %q", got, tt.wantLimit)
+			}
+			if got := recorder.Header().Get("RateLimit-Policy"); got != tt.wantPolicy {
+				t.Errorf("RateLimit-Policy = %q, kutilgan %q", got, tt.wantPolicy)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wireguard-vpn-client-creater/pkg/config"
+	"wireguard-vpn-client-creater/pkg/database"
+	"wireguard-vpn-client-creater/pkg/models"
+	"wireguard-vpn-client-creater/pkg/security"
+)
+
+// RequireIPBlocker - IP bloklash tizimi o'chirilgan bo'lsa so'rovni rad etuvchi middleware
+func RequireIPBlocker() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ipBlocker == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "IP bloklash tizimi o'chirilgan"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// reloadAccessLists - Konfiguratsiya va databasedagi ruxsat/taqiq ro'yxatlarini IP bloklash tizimiga yuklash
+func reloadAccessLists() error {
+	ipBlockerConfig := config.Config.Security.IPBlocker
+	allowlist, err := security.ParseCIDRs(ipBlockerConfig.Allowlist)
+	if err != nil {
+		return fmt.Errorf("security.ip_blocker.allowlist: %v", err)
+	}
+	denylist, err := security.ParseCIDRs(ipBlockerConfig.Denylist)
+	if err != nil {
+		return fmt.Errorf("security.ip_blocker.denylist: %v", err)
+	}
+
+	rules, err := database.GetIPAccessRules()
+	if err != nil {
+		return fmt.Errorf("ro'yxatlarni databasedan o'qishda xatolik: %v", err)
+	}
+	for _, rule := range rules {
+		network, err := security.ParseCIDR(rule.CIDR)
+		if err != nil {
+			return err
+		}
+		if rule.List == security.AccessListAllow {
+			allowlist = append(allowlist, network)
+		} else {
+			denylist = append(denylist, network)
+		}
+	}
+
+	ipBlocker.SetAccessLists(allowlist, denylist)
+	return nil
+}
+
+// blockResponse - Blokni API javobi uchun tayyorlash
+func blockResponse(block security.Block, attempts int) gin.H {
+	response := gin.H{
+		"ip":         block.IP,
+		"reason":     block.Reason,
+		"attempts":   attempts,
+		"offense":    block.Offense,
+		"blocked_at": block.BlockedAt,
+		"permanent":  block.Permanent(),
+		"expires_at": nil,
+	}
+	if !block.Permanent() {
+		remaining := time.Until(block.ExpiresAt).Round(time.Second)
+		response["expires_at"] = block.ExpiresAt
+		response["remaining_seconds"] = int64(remaining / time.Second)
+		response["remaining"] = remaining.String()
+	}
+	return response
+}
+
+// GetBlockedIPsHandler - Hozirda bloklangan IP manzillar ro'yxati
+func GetBlockedIPsHandler(c *gin.Context) {
+	blocks := ipBlocker.List()
+	response := make([]gin.H, 0, len(blocks))
+	for _, block := range blocks {
+		response = append(response, blockResponse(block.Block, block.Attempts))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  response,
+		"total": len(response),
+	})
+}
+
+// BlockIPHandler - IP manzil yoki subnetni (CIDR) qo'lda bloklash
+func BlockIPHandler(c *gin.Context) {
+	var request struct {
+		IP       string `json:"ip"`
+		Duration int64  `json:"duration"` // Soniyalarda, 0 = muddatsiz
+		Reason   string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri so'rov formati"})
+		return
+	}
+
+	network, err := security.ParseCIDR(request.IP)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Duration < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "duration manfiy bo'lishi mumkin emas"})
+		return
+	}
+
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		reason = security.BlockReasonManual
+	}
+
+	block, err := ipBlocker.BlockIP(security.NetworkKey(network), time.Duration(request.Duration)*time.Second, reason)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := blockResponse(block, 0)
+	database.RecordAuditDetails(auditActor(c), c.ClientIP(), database.AuditIPBlock, nil, gin.H{
+		"ip":         block.IP,
+		"reason":     block.Reason,
+		"duration":   request.Duration,
+		"expires_at": response["expires_at"],
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    response,
+		"message": "IP manzil bloklandi",
+	})
+}
+
+// UnblockIPHandler - IP manzil yoki subnetni blokdan chiqarish (subnet /ip/prefix ko'rinishida beriladi)
+func UnblockIPHandler(c *gin.Context) {
+	network, err := security.ParseCIDR(strings.TrimPrefix(c.Param("ip"), "/"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key := security.NetworkKey(network)
+
+	if !ipBlocker.Unblock(key) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "IP manzil bloklanmagan"})
+		return
+	}
+
+	database.RecordAuditDetails(auditActor(c), c.ClientIP(), database.AuditIPUnblock, nil, gin.H{"ip": key})
+
+	response := gin.H{"ip": key, "message": "IP manzil blokdan chiqarildi"}
+	if block, blocked := ipBlocker.Lookup(network.IP.String()); blocked {
+		if block.Reason == security.BlockReasonDenylist {
+			response["warning"] = "IP manzil taqiq ro'yxatida, u bloklangan holda qoladi"
+		} else {
+			response["warning"] = fmt.Sprintf("IP manzil %s bloki ostida qoladi", block.IP)
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetAccessListsHandler - Ruxsat va taqiq ro'yxatlari (konfiguratsiyadagilar o'zgartirilmaydi)
+func GetAccessListsHandler(c *gin.Context) {
+	rules, err := database.GetIPAccessRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ro'yxatlarni olishda xatolik: " + err.Error()})
+		return
+	}
+
+	lists := gin.H{
+		security.AccessListAllow: accessEntries(config.Config.Security.IPBlocker.Allowlist, rules, security.AccessListAllow),
+		security.AccessListDeny:  accessEntries(config.Config.Security.IPBlocker.Denylist, rules, security.AccessListDeny),
+	}
+	c.JSON(http.StatusOK, lists)
+}
+
+// accessEntries - Bitta ro'yxatning konfiguratsiya va database yozuvlari
+func accessEntries(configured []string, rules []models.IPAccessRule, list string) []gin.H {
+	entries := make([]gin.H, 0, len(configured))
+	for _, cidr := range configured {
+		entries = append(entries, gin.H{"id": nil, "cidr": cidr, "source": "config"})
+	}
+	for _, rule := range rules {
+		if rule.List != list {
+			continue
+		}
+		entries = append(entries, gin.H{
+			"id":         rule.ID,
+			"cidr":       rule.CIDR,
+			"comment":    rule.Comment,
+			"created_at": rule.CreatedAt,
+			"created_by": rule.CreatedBy,
+			"source":     "database",
+		})
+	}
+	return entries
+}
+
+// CreateAccessRuleHandler - Ruxsat yoki taqiq ro'yxatiga CIDR qo'shish
+func CreateAccessRuleHandler(c *gin.Context) {
+	var request struct {
+		List    string `json:"list"`
+		CIDR    string `json:"cidr"`
+		Comment string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri so'rov formati"})
+		return
+	}
+
+	if request.List != security.AccessListAllow && request.List != security.AccessListDeny {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "list 'allow' yoki 'deny' bo'lishi kerak"})
+		return
+	}
+	network, err := security.ParseCIDR(request.CIDR)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := &models.IPAccessRule{
+		List:      request.List,
+		CIDR:      network.String(),
+		Comment:   strings.TrimSpace(request.Comment),
+		CreatedBy: auditActor(c),
+	}
+	err = database.CreateIPAccessRule(rule)
+	if errors.Is(err, database.ErrAccessRuleExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bu CIDR ro'yxatda allaqachon bor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ro'yxatga qo'shishda xatolik: " + err.Error()})
+		return
+	}
+
+	if err := reloadAccessLists(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	database.RecordAuditDetails(auditActor(c), c.ClientIP(), database.AuditAccessCreate, nil, gin.H{
+		"id":      rule.ID,
+		"list":    rule.List,
+		"cidr":    rule.CIDR,
+		"comment": rule.Comment,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"data": rule, "message": "Ro'yxatga qo'shildi"})
+}
+
+// DeleteAccessRuleHandler - Ruxsat yoki taqiq ro'yxatidan yozuvni o'chirish
+func DeleteAccessRuleHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri ID formati"})
+		return
+	}
+
+	rule, err := database.DeleteIPAccessRule(uint(id))
+	if errors.Is(err, database.ErrAccessRuleNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ro'yxat yozuvi topilmadi"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ro'yxat yozuvini o'chirishda xatolik: " + err.Error()})
+		return
+	}
+
+	if err := reloadAccessLists(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	database.RecordAuditDetails(auditActor(c), c.ClientIP(), database.AuditAccessDelete, nil, gin.H{
+		"id":   rule.ID,
+		"list": rule.List,
+		"cidr": rule.CIDR,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ro'yxat yozuvi o'chirildi"})
+}
@@ -0,0 +1,473 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wireguard-vpn-client-creater/pkg/auth"
+	"wireguard-vpn-client-creater/pkg/config"
+	"wireguard-vpn-client-creater/pkg/database"
+	"wireguard-vpn-client-creater/pkg/metrics"
+	"wireguard-vpn-client-creater/pkg/models"
+)
+
+// totpIssuer - Autentifikator ilovasida ko'rinadigan nom
+const totpIssuer = "WireGuard VPN"
+
+// sessionTTL - Sessiya muddati (konfiguratsiyada ko'rsatilmagan bo'lsa 12 soat)
+func sessionTTL() time.Duration {
+	if hours := config.Config.Auth.SessionTTL; hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return 12 * time.Hour
+}
+
+// currentUser - Sessiya orqali kirgan foydalanuvchi va uning sessiyasi
+func currentUser(c *gin.Context) (*models.AdminUser, *models.UserSession) {
+	user, _ := c.MustGet(UserContextKey).(*models.AdminUser)
+	session, _ := c.MustGet(SessionContextKey).(*models.UserSession)
+	return user, session
+}
+
+// recordLoginFailure - Muvaffaqiyatsiz login urinishini qayd qilish
+func recordLoginFailure(c *gin.Context, reason string) {
+	metrics.RecordAuthFailure(reason)
+	if ipBlocker != nil && config.Config.Security.IPBlocker.Enabled {
+		ipBlocker.RecordFailedAttempt(c.ClientIP(), c.Request.UserAgent(), c.Request.URL.Path)
+	}
+}
+
+// acceptTOTP - TOTP kodini tekshirish va u qayta ishlatilmasligi uchun oralig'ini saqlash
+func acceptTOTP(user *models.AdminUser, code string) (bool, error) {
+	step, ok := auth.ValidateTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return false, nil
+	}
+
+	accepted, err := database.AcceptTOTPStep(user.ID, step)
+	if err != nil || !accepted {
+		return false, err
+	}
+	user.TOTPLastStep = step
+	return true, nil
+}
+
+// LoginHandler - Username va parol (va yoqilgan bo'lsa TOTP kodi) bilan kirish, sessiya cookiesini o'rnatish
+func LoginHandler(c *gin.Context) {
+	clientIP := c.ClientIP()
+	if ipBlocker != nil && config.Config.Security.IPBlocker.Enabled {
+		if block, blocked := ipBlocker.Lookup(clientIP); blocked {
+			abortBlocked(c, block)
+			return
+		}
+	}
+
+	var request struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		TOTPCode string `json:"totp_code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri so'rov formati"})
+		return
+	}
+
+	user, err := database.GetAdminUserByUsername(strings.TrimSpace(request.Username))
+	if err != nil && !errors.Is(err, database.ErrUserNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Foydalanuvchini olishda xatolik: " + err.Error()})
+		return
+	}
+	// Foydalanuvchi topilmasa ham bcrypt bajariladi, aks holda javob vaqti username mavjudligini bildiradi
+	var passwordOK bool
+	if err != nil {
+		passwordOK = auth.CheckDummyPassword(request.Password)
+	} else {
+		passwordOK = auth.CheckPassword(user.PasswordHash, request.Password)
+	}
+	if !passwordOK || user.DisabledAt != nil {
+		recordLoginFailure(c, "invalid_password")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Noto'g'ri username yoki parol"})
+		return
+	}
+
+	if user.TOTPEnabled {
+		if request.TOTPCode == "" {
+			// Parol to'g'ri ekani javobdan ma'lum bo'ladi, shuning uchun bu ham urinish sifatida hisoblanadi
+			recordLoginFailure(c, "totp_required")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "TOTP kodi talab qilinadi", "totp_required": true})
+			return
+		}
+		accepted, err := acceptTOTP(user, request.TOTPCode)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "TOTP kodini saqlashda xatolik: " + err.Error()})
+			return
+		}
+		if !accepted {
+			recordLoginFailure(c, "invalid_totp")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Noto'g'ri TOTP kodi", "totp_required": true})
+			return
+		}
+	}
+
+	ttl := sessionTTL()
+	token, session, err := database.CreateUserSession(user, ttl, clientIP, c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Sessiya yaratishda xatolik: " + err.Error()})
+		return
+	}
+
+	if ipBlocker != nil && config.Config.Security.IPBlocker.Enabled {
+		ipBlocker.ResetFailedAttempts(clientIP)
+	}
+
+	database.RecordAuditDetails("user:"+user.Username, clientIP, database.AuditUserLogin, nil, gin.H{
+		"user_id":    user.ID,
+		"session_id": session.ID,
+	})
+
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(SessionCookieName, token, int(ttl.Seconds()), "/", "", config.Config.Auth.CookieSecure, true)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       user,
+		"scopes":     auth.ScopesForRole(user.Role),
+		"expires_at": session.ExpiresAt,
+	})
+}
+
+// LogoutHandler - Joriy sessiyani tugatish va cookieni o'chirish
+func LogoutHandler(c *gin.Context) {
+	user, session := currentUser(c)
+	if err := database.DeleteUserSession(session.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Sessiyani tugatishda xatolik: " + err.Error()})
+		return
+	}
+
+	database.RecordAuditDetails(auditActor(c), c.ClientIP(), database.AuditUserLogout, nil, gin.H{
+		"user_id":    user.ID,
+		"session_id": session.ID,
+	})
+
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(SessionCookieName, "", -1, "/", "", config.Config.Auth.CookieSecure, true)
+	c.JSON(http.StatusOK, gin.H{"message": "Sessiya tugatildi"})
+}
+
+// GetCurrentUserHandler - Joriy foydalanuvchi va uning ruxsatlari
+func GetCurrentUserHandler(c *gin.Context) {
+	user, session := currentUser(c)
+	c.JSON(http.StatusOK, gin.H{
+		"data":       user,
+		"scopes":     auth.ScopesForRole(user.Role),
+		"expires_at": session.ExpiresAt,
+	})
+}
+
+// SetupTOTPHandler - Yangi TOTP kalitini yaratish. Kalit kod bilan tasdiqlangandan keyin yoqiladi.
+func SetupTOTPHandler(c *gin.Context) {
+	user, _ := currentUser(c)
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "TOTP allaqachon yoqilgan"})
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	user.TOTPSecret = secret
+	if err := database.UpdateAdminUser(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "TOTP kalitini saqlashda xatolik: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":  secret,
+		"uri":     auth.TOTPURI(totpIssuer, user.Username, secret),
+		"message": "Kalitni autentifikator ilovasiga qo'shing va /api/auth/totp/enable orqali kod bilan tasdiqlang",
+	})
+}
+
+// EnableTOTPHandler - TOTP kodini tekshirib, ikki bosqichli autentifikatsiyani yoqish
+func EnableTOTPHandler(c *gin.Context) {
+	user, _ := currentUser(c)
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "TOTP allaqachon yoqilgan"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Avval /api/auth/totp/setup orqali kalit yarating"})
+		return
+	}
+
+	var request struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri so'rov formati"})
+		return
+	}
+	accepted, err := acceptTOTP(user, request.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "TOTP kodini saqlashda xatolik: " + err.Error()})
+		return
+	}
+	if !accepted {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri TOTP kodi"})
+		return
+	}
+
+	user.TOTPEnabled = true
+	if err := database.UpdateAdminUser(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "TOTP ni yoqishda xatolik: " + err.Error()})
+		return
+	}
+
+	database.RecordAuditDetails(auditActor(c), c.ClientIP(), database.AuditTOTPEnable, nil, gin.H{"user_id": user.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "TOTP yoqildi"})
+}
+
+// DisableTOTPHandler - Joriy TOTP kodi bilan ikki bosqichli autentifikatsiyani o'chirish
+func DisableTOTPHandler(c *gin.Context) {
+	user, _ := currentUser(c)
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "TOTP yoqilmagan"})
+		return
+	}
+
+	var request struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri so'rov formati"})
+		return
+	}
+	accepted, err := acceptTOTP(user, request.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "TOTP kodini saqlashda xatolik: " + err.Error()})
+		return
+	}
+	if !accepted {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri TOTP kodi"})
+		return
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	if err := database.UpdateAdminUser(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "TOTP ni o'chirishda xatolik: " + err.Error()})
+		return
+	}
+
+	database.RecordAuditDetails(auditActor(c), c.ClientIP(), database.AuditTOTPDisable, nil, gin.H{"user_id": user.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "TOTP o'chirildi"})
+}
+
+// CreateUserHandler - Yangi web interfeys foydalanuvchisini yaratish
+func CreateUserHandler(c *gin.Context) {
+	var request struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Role     string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri so'rov formati"})
+		return
+	}
+
+	request.Username = strings.TrimSpace(request.Username)
+	if request.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username ko'rsatilishi kerak"})
+		return
+	}
+	if !auth.ValidRole(request.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noma'lum rol: " + request.Role})
+		return
+	}
+
+	if _, err := database.GetAdminUserByUsername(request.Username); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bu username band"})
+		return
+	} else if !errors.Is(err, database.ErrUserNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Foydalanuvchini tekshirishda xatolik: " + err.Error()})
+		return
+	}
+
+	hash, err := auth.HashPassword(request.Password)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := &models.AdminUser{
+		Username:     request.Username,
+		PasswordHash: hash,
+		Role:         request.Role,
+	}
+	if err := database.CreateAdminUser(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Foydalanuvchini yaratishda xatolik: " + err.Error()})
+		return
+	}
+
+	database.RecordAuditDetails(auditActor(c), c.ClientIP(), database.AuditUserCreate, nil, gin.H{
+		"id":       user.ID,
+		"username": user.Username,
+		"role":     user.Role,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"data": user, "message": "Foydalanuvchi yaratildi"})
+}
+
+// GetUsersHandler - Barcha web interfeys foydalanuvchilari ro'yxati
+func GetUsersHandler(c *gin.Context) {
+	users, err := database.GetAllAdminUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Foydalanuvchilarni olishda xatolik: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// UpdateUserHandler - Foydalanuvchi rolini, parolini yoki holatini o'zgartirish.
+// Parol o'zgarganda yoki foydalanuvchi bloklanganda uning barcha sessiyalari tugatiladi.
+func UpdateUserHandler(c *gin.Context) {
+	user, ok := userFromParam(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Role     *string `json:"role"`
+		Password *string `json:"password"`
+		Disabled *bool   `json:"disabled"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri so'rov formati"})
+		return
+	}
+
+	// Administrator o'zini bloklab yoki rolini tushirib, tizimga kirish imkonini yo'qotmasligi kerak
+	if isCurrentUser(c, user) {
+		if (request.Role != nil && *request.Role != auth.RoleAdmin) || (request.Disabled != nil && *request.Disabled) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "O'z rolingizni tushirib yoki o'zingizni bloklab bo'lmaydi"})
+			return
+		}
+	}
+
+	changes := gin.H{}
+	endSessions := false
+
+	if request.Role != nil && *request.Role != user.Role {
+		if !auth.ValidRole(*request.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Noma'lum rol: " + *request.Role})
+			return
+		}
+		changes["role"] = database.AuditChange{Before: user.Role, After: *request.Role}
+		user.Role = *request.Role
+	}
+	if request.Password != nil {
+		hash, err := auth.HashPassword(*request.Password)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user.PasswordHash = hash
+		changes["password"] = "changed"
+		endSessions = true
+	}
+	if request.Disabled != nil && *request.Disabled != (user.DisabledAt != nil) {
+		if *request.Disabled {
+			now := time.Now()
+			user.DisabledAt = &now
+			endSessions = true
+		} else {
+			user.DisabledAt = nil
+		}
+		changes["disabled"] = database.AuditChange{Before: !*request.Disabled, After: *request.Disabled}
+	}
+
+	if err := database.UpdateAdminUser(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Foydalanuvchini yangilashda xatolik: " + err.Error()})
+		return
+	}
+	if endSessions {
+		if err := database.DeleteUserSessions(user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Sessiyalarni tugatishda xatolik: " + err.Error()})
+			return
+		}
+	}
+
+	if len(changes) > 0 {
+		database.RecordAuditDetails(auditActor(c), c.ClientIP(), database.AuditUserUpdate, nil, gin.H{
+			"id":       user.ID,
+			"username": user.Username,
+			"changes":  changes,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": user, "message": "Foydalanuvchi yangilandi"})
+}
+
+// DeleteUserHandler - Foydalanuvchini va uning sessiyalarini o'chirish
+func DeleteUserHandler(c *gin.Context) {
+	user, ok := userFromParam(c)
+	if !ok {
+		return
+	}
+
+	if isCurrentUser(c, user) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "O'zingizni o'chirib bo'lmaydi"})
+		return
+	}
+
+	if err := database.DeleteAdminUser(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Foydalanuvchini o'chirishda xatolik: " + err.Error()})
+		return
+	}
+
+	database.RecordAuditDetails(auditActor(c), c.ClientIP(), database.AuditUserDelete, nil, gin.H{
+		"id":       user.ID,
+		"username": user.Username,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Foydalanuvchi o'chirildi"})
+}
+
+// userFromParam - URL dagi ID bo'yicha foydalanuvchini olish (xatolik javobi shu yerda yuboriladi)
+func userFromParam(c *gin.Context) (*models.AdminUser, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Noto'g'ri ID formati"})
+		return nil, false
+	}
+
+	user, err := database.GetAdminUserByID(uint(id))
+	if errors.Is(err, database.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Foydalanuvchi topilmadi"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Foydalanuvchini olishda xatolik: " + err.Error()})
+		return nil, false
+	}
+
+	return user, true
+}
+
+// isCurrentUser - So'rov aynan shu foydalanuvchining sessiyasidan kelganmi
+func isCurrentUser(c *gin.Context, user *models.AdminUser) bool {
+	value, ok := c.Get(UserContextKey)
+	if !ok {
+		return false
+	}
+	current, _ := value.(*models.AdminUser)
+	return current != nil && current.ID == user.ID
+}
@@ -0,0 +1,396 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"wireguard-vpn-client-creater/pkg/config"
+)
+
+// jwksMinRefresh - Noma'lum "kid" uchun JWKS ni qayta yuklashlar orasidagi minimal vaqt
+const jwksMinRefresh = 30 * time.Second
+
+// jwtLeeway - Server va identity provider soatlari orasidagi ruxsat etilgan farq
+const jwtLeeway = 30 * time.Second
+
+// jwtMethods - Faqat asimmetrik algoritmlar qabul qilinadi (HMAC bilan kalit almashtirish hujumining oldini olish)
+var jwtMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// ErrInvalidJWT - Token imzosi, muddati yoki claimlari yaroqsiz
+var ErrInvalidJWT = errors.New("yaroqsiz JWT")
+
+// ErrJWKSUnavailable - JWKS hali bir marta ham yuklanmagan, shuning uchun token imzosini tekshirib bo'lmaydi
+var ErrJWKSUnavailable = errors.New("JWKS mavjud emas")
+
+// JWTIdentity - Tekshirilgan tokendan olingan foydalanuvchi va uning ruxsatlari
+type JWTIdentity struct {
+	Subject string
+	Scopes  []string
+}
+
+// JWTVerifier - Identity provider imzolagan JWT larni tekshiruvchi
+type JWTVerifier struct {
+	config    config.JWTConfig
+	staticKey crypto.PublicKey
+	client    *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	fetching    chan struct{} // JWKS yuklanayotganda yopilishini kutish uchun, aks holda nil
+}
+
+// NewJWTVerifier - Konfiguratsiya bo'yicha tekshiruvchini yaratish. Statik kalit fayli darhol o'qiladi.
+func NewJWTVerifier(cfg config.JWTConfig) (*JWTVerifier, error) {
+	if cfg.Issuer == "" {
+		return nil, fmt.Errorf("jwt.issuer ko'rsatilishi kerak")
+	}
+	if (cfg.JWKSURL == "") == (cfg.KeyFile == "") {
+		return nil, fmt.Errorf("jwt.jwks_url yoki jwt.key_file dan faqat bittasi ko'rsatilishi kerak")
+	}
+
+	verifier := &JWTVerifier{
+		config: cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if cfg.KeyFile != "" {
+		key, err := loadPublicKeyFile(cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		verifier.staticKey = key
+	}
+
+	return verifier, nil
+}
+
+// LooksLikeJWT - Token uch qismli JWT formatida ekanligini tekshirish
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Verify - Token imzosi, issuer, audience va muddatini tekshirib, ruxsatlarni claimlardan aniqlash.
+// Yaroqsiz token uchun ErrInvalidJWT, JWKS hali yuklanmagan bo'lsa ErrJWKSUnavailable qaytariladi.
+func (v *JWTVerifier) Verify(token string) (*JWTIdentity, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(jwtMethods),
+		jwt.WithIssuer(v.config.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+	}
+	if v.config.Audience != "" {
+		options = append(options, jwt.WithAudience(v.config.Audience))
+	}
+
+	var keyErr error
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		key, err := v.key(t)
+		if err != nil && !errors.Is(err, ErrInvalidJWT) {
+			keyErr = err
+		}
+		return key, err
+	}, options...)
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJWT, err)
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("%w: sub claim bo'sh", ErrInvalidJWT)
+	}
+
+	return &JWTIdentity{Subject: subject, Scopes: v.claimScopes(claims)}, nil
+}
+
+// claimScopes - Scope va rol claimlarini mahalliy ruxsatlarga o'tkazish. Noma'lum qiymatlar e'tiborsiz qoldiriladi.
+func (v *JWTVerifier) claimScopes(claims jwt.MapClaims) []string {
+	seen := make(map[string]bool)
+	var scopes []string
+	add := func(scope string) {
+		if isKnownScope(scope) && !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	scopeClaim := v.config.ScopeClaim
+	if scopeClaim == "" {
+		scopeClaim = "scope"
+	}
+	for _, scope := range claimStrings(claims[scopeClaim]) {
+		add(scope)
+	}
+
+	roleClaim := v.config.RoleClaim
+	if roleClaim == "" {
+		roleClaim = "roles"
+	}
+	for _, value := range claimStrings(claims[roleClaim]) {
+		role := value
+		if len(v.config.RoleMap) > 0 {
+			role = v.config.RoleMap[value]
+		}
+		for _, scope := range ScopesForRole(role) {
+			add(scope)
+		}
+	}
+
+	return scopes
+}
+
+// claimStrings - Claim qiymatini satrlar ro'yxatiga o'tkazish ("a b" satri yoki ["a", "b"] ro'yxati)
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// key - Token sarlavhasidagi "kid" bo'yicha ochiq kalitni topish
+func (v *JWTVerifier) key(token *jwt.Token) (crypto.PublicKey, error) {
+	if v.staticKey != nil {
+		return v.staticKey, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if err := v.refreshKeys(kid); err != nil {
+		return nil, err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.keys == nil {
+		return nil, fmt.Errorf("%w: JWKS hali yuklanmagan", ErrJWKSUnavailable)
+	}
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: noma'lum kid %q", ErrInvalidJWT, kid)
+}
+
+// refreshKeys - Kerak bo'lsa JWKS ni qayta yuklash. HTTP so'rovi qulfdan tashqarida bajariladi:
+// bir vaqtda faqat bitta yuklash ishlaydi, "kid" i noma'lum so'rovlar uni kutadi, qolganlari oldingi kalitlar bilan davom etadi.
+func (v *JWTVerifier) refreshKeys(kid string) error {
+	v.mu.Lock()
+
+	refresh := time.Duration(v.config.JWKSRefresh) * time.Minute
+	if refresh <= 0 {
+		refresh = time.Hour
+	}
+	_, known := v.keys[kid]
+
+	if done := v.fetching; done != nil {
+		v.mu.Unlock()
+		if !known {
+			<-done
+		}
+		return nil
+	}
+
+	// Kalit almashtirilganda yangi "kid" paydo bo'ladi, lekin identity providerga so'rovlar cheklanadi
+	if (known && time.Since(v.fetchedAt) < refresh) || time.Since(v.attemptedAt) < jwksMinRefresh {
+		v.mu.Unlock()
+		return nil
+	}
+
+	v.attemptedAt = time.Now()
+	done := make(chan struct{})
+	v.fetching = done
+	v.mu.Unlock()
+
+	keys, err := v.fetchJWKS()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.fetching = nil
+	close(done)
+
+	if err != nil {
+		if v.keys == nil {
+			return fmt.Errorf("%w: %v", ErrJWKSUnavailable, err)
+		}
+		// Oldingi kalitlar bilan ishlashda davom etish
+		log.Printf("JWT: %v (oldingi kalitlar ishlatiladi)", err)
+		return nil
+	}
+
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	return nil
+}
+
+// fetchJWKS - JWKS manzilidan ochiq kalitlarni yuklash (v.mu qulflanmagan holda chaqiriladi)
+func (v *JWTVerifier) fetchJWKS() (map[string]crypto.PublicKey, error) {
+	resp, err := v.client.Get(v.config.JWKSURL)
+	if err != nil {
+		return nil, fmt.Errorf("JWKS ni yuklashda xatolik: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS ni yuklashda xatolik: HTTP %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("JWKS formatini o'qishda xatolik: %v", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Qo'llab-quvvatlanmaydigan kalitlar boshqalarini ishlatishga to'sqinlik qilmaydi
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
+
+// jsonWebKey - JWKS dagi bitta kalit (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey - JWK ni Go ochiq kalitiga o'tkazish (RSA, EC va Ed25519)
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("qo'llab-quvvatlanmaydigan egri chiziq: %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("qo'llab-quvvatlanmaydigan egri chiziq: %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("noto'g'ri Ed25519 kalit uzunligi")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("qo'llab-quvvatlanmaydigan kalit turi: %s", k.Kty)
+}
+
+// loadPublicKeyFile - PEM fayldan ochiq kalit yoki sertifikat kalitini o'qish
+func loadPublicKeyFile(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("JWT kalit faylini o'qishda xatolik: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("JWT kalit fayli PEM formatida emas: %s", path)
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("sertifikatni o'qishda xatolik: %v", err)
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("RSA kalitini o'qishda xatolik: %v", err)
+		}
+		return key, nil
+	default:
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("ochiq kalitni o'qishda xatolik: %v", err)
+		}
+		return key, nil
+	}
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"wireguard-vpn-client-creater/pkg/config"
+)
+
+// newJWKSServer - Bitta Ed25519 kaliti bor JWKS server. Har bir so'rov requests kanaliga yoziladi va
+// release kanalidan ruxsat kelguncha javob berilmaydi.
+func newJWKSServer(t *testing.T, kid string) (server *httptest.Server, requests chan struct{}, release chan struct{}, count *int32) {
+	t.Helper()
+
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey xatolik: %v", err)
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"keys": []jsonWebKey{{Kty: "OKP", Crv: "Ed25519", Kid: kid, X: base64.RawURLEncoding.EncodeToString(publicKey)}},
+	})
+
+	requests = make(chan struct{}, 10)
+	release = make(chan struct{}, 10)
+	count = new(int32)
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(count, 1)
+		requests <- struct{}{}
+		<-release
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests, release, count
+}
+
+func TestJWTVerifierFetchesOutsideLock(t *testing.T) {
+	server, requests, release, count := newJWKSServer(t, "k1")
+
+	verifier, err := NewJWTVerifier(config.JWTConfig{Issuer: "https://idp.example.com", JWKSURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewJWTVerifier xatolik: %v", err)
+	}
+	token := &jwt.Token{Header: map[string]interface{}{"kid": "k1"}}
+
+	// Birinchi yuklash
+	release <- struct{}{}
+	if _, err := verifier.key(token); err != nil {
+		t.Fatalf("key() xatolik: %v", err)
+	}
+	<-requests
+
+	// Kalitlar eskirgan: keyingi so'rov qayta yuklashni boshlaydi, server esa javob bermay turadi
+	verifier.mu.Lock()
+	verifier.fetchedAt = time.Now().Add(-2 * time.Hour)
+	verifier.attemptedAt = time.Now().Add(-time.Hour)
+	verifier.mu.Unlock()
+
+	refreshed := make(chan error, 1)
+	go func() {
+		_, err := verifier.key(token)
+		refreshed <- err
+	}()
+	<-requests
+
+	// Yuklash davom etayotganda ma'lum "kid" bilan so'rovlar kutmasligi kerak
+	done := make(chan error, 1)
+	go func() {
+		_, err := verifier.key(token)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("yuklash paytida key() xatolik: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("key() JWKS yuklanishini kutib qoldi")
+	}
+
+	release <- struct{}{}
+	if err := <-refreshed; err != nil {
+		t.Errorf("qayta yuklashdan keyin key() xatolik: %v", err)
+	}
+	if got := atomic.LoadInt32(count); got != 2 {
+		t.Errorf("JWKS so'rovlari = %d, kutilgan 2", got)
+	}
+}
+
+func TestJWTVerifierSingleFetch(t *testing.T) {
+	server, requests, release, count := newJWKSServer(t, "k1")
+
+	verifier, err := NewJWTVerifier(config.JWTConfig{Issuer: "https://idp.example.com", JWKSURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewJWTVerifier xatolik: %v", err)
+	}
+	token := &jwt.Token{Header: map[string]interface{}{"kid": "k1"}}
+
+	// Kalitlar hali yuklanmagan: barcha so'rovlar bitta yuklashni kutadi
+	const callers = 5
+	results := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			_, err := verifier.key(token)
+			results <- err
+		}()
+	}
+
+	<-requests
+	release <- struct{}{}
+	for i := 0; i < callers; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("key() xatolik: %v", err)
+		}
+	}
+	if got := atomic.LoadInt32(count); got != 1 {
+		t.Errorf("JWKS so'rovlari = %d, kutilgan 1", got)
+	}
+}
+
+func TestJWTVerifierJWKSUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(server.Close)
+
+	verifier, err := NewJWTVerifier(config.JWTConfig{Issuer: "https://idp.example.com", JWKSURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewJWTVerifier xatolik: %v", err)
+	}
+	token := &jwt.Token{Header: map[string]interface{}{"kid": "k1"}}
+
+	// Birinchi urinish yuklash xatoligini, keyingisi (qayta yuklash cheklangan) yuklanmagan kalitlarni qaytaradi
+	for i := 0; i < 2; i++ {
+		if _, err := verifier.key(token); !errors.Is(err, ErrJWKSUnavailable) {
+			t.Errorf("%d-urinish: key() xatolik = %v, kutilgan ErrJWKSUnavailable", i+1, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength - Parolning minimal uzunligi
+const MinPasswordLength = 8
+
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// HashPassword - Parolni bcrypt bilan xeshlash
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("parol kamida %d belgidan iborat bo'lishi kerak", MinPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("parolni xeshlashda xatolik: %v", err)
+	}
+	return string(hash), nil
+}
+
+// CheckPassword - Parol xeshga mos kelishini tekshirish
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// CheckDummyPassword - Foydalanuvchi topilmaganda ham haqiqiy tekshiruv bilan bir xil vaqt sarflash.
+// Javob vaqtidan username mavjudligini aniqlab bo'lmasligi uchun; natija doim false.
+func CheckDummyPassword(password string) bool {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+	return false
+}
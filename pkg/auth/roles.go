@@ -0,0 +1,26 @@
+package auth
+
+// Administrator foydalanuvchilari rollari
+const (
+	RoleViewer   = "viewer"   // Faqat o'qish
+	RoleOperator = "operator" // Clientlarni boshqarish
+	RoleAdmin    = "admin"    // Barcha amallar, foydalanuvchilar va kalitlarni boshqarish
+)
+
+// roleScopes - Har bir rol API kalitlari bilan bir xil ruxsatlarga moslanadi
+var roleScopes = map[string][]string{
+	RoleViewer:   {ScopeClientsRead, ScopeServerRead},
+	RoleOperator: {ScopeClientsWrite, ScopeServerRead},
+	RoleAdmin:    {ScopeAdmin},
+}
+
+// ScopesForRole - Rolga mos ruxsatlar ro'yxati (noma'lum rol uchun bo'sh)
+func ScopesForRole(role string) []string {
+	return roleScopes[role]
+}
+
+// ValidRole - Rol mavjudligini tekshirish
+func ValidRole(role string) bool {
+	_, ok := roleScopes[role]
+	return ok
+}
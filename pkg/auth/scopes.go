@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// API ruxsatlari (scope)
+const (
+	ScopeClientsRead  = "clients:read"
+	ScopeClientsWrite = "clients:write"
+	ScopeServerRead   = "server:read"
+	ScopeAdmin        = "admin" // Barcha ruxsatlarni o'z ichiga oladi
+)
+
+// AllScopes - Mavjud barcha ruxsatlar
+var AllScopes = []string{ScopeClientsRead, ScopeClientsWrite, ScopeServerRead, ScopeAdmin}
+
+// impliedScopes - Boshqa ruxsatni ham beradigan ruxsatlar (yozish huquqi o'qishni ham beradi)
+var impliedScopes = map[string][]string{
+	ScopeClientsWrite: {ScopeClientsRead},
+}
+
+// HasScope - Berilgan ruxsatlar ro'yxati required ruxsatni qamrab olishini tekshirish
+func HasScope(granted []string, required string) bool {
+	for _, scope := range granted {
+		if scope == ScopeAdmin || scope == required {
+			return true
+		}
+		for _, implied := range impliedScopes[scope] {
+			if implied == required {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateScopes - Ruxsatlar ro'yxatida noma'lum qiymat yo'qligini tekshirish
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("kamida bitta scope ko'rsatilishi kerak")
+	}
+	for _, scope := range scopes {
+		if !isKnownScope(scope) {
+			return fmt.Errorf("noma'lum scope: %s (mavjudlari: %s)", scope, strings.Join(AllScopes, ", "))
+		}
+	}
+	return nil
+}
+
+// JoinScopes - Ruxsatlarni databasega saqlash uchun satrga o'tkazish
+func JoinScopes(scopes []string) string {
+	return strings.Join(scopes, ",")
+}
+
+// SplitScopes - Databasedagi satrni ruxsatlar ro'yxatiga o'tkazish
+func SplitScopes(value string) []string {
+	var scopes []string
+	for _, scope := range strings.Split(value, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// isKnownScope - Ruxsat mavjudlar ro'yxatida borligini tekshirish
+func isKnownScope(scope string) bool {
+	for _, known := range AllScopes {
+		if known == scope {
+			return true
+		}
+	}
+	return false
+}
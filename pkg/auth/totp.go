@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parametrlari (RFC 6238, Google Authenticator bilan mos)
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+	totpSkew   = 1 // Soatlar farqi uchun oldingi va keyingi oraliqlar ham qabul qilinadi
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret - Yangi TOTP kaliti yaratish (base32)
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("TOTP kaliti yaratishda xatolik: %v", err)
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TOTPCode - Berilgan vaqt uchun TOTP kodini hisoblash
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return "", fmt.Errorf("TOTP kaliti noto'g'ri: %v", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(totpStep(t)))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dinamik kesish (RFC 4226)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, code%1000000), nil
+}
+
+// ValidateTOTP - Foydalanuvchi kiritgan kodni tekshirish va mos kelgan oraliq raqamini qaytarish.
+// lastStep va undan oldingi oraliqlar kodlari qabul qilinmaydi (bir kodni ikki marta ishlatib bo'lmaydi).
+func ValidateTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	for i := -totpSkew; i <= totpSkew; i++ {
+		t := now.Add(time.Duration(i) * totpPeriod)
+		step := totpStep(t)
+		if step <= lastStep {
+			continue
+		}
+
+		expected, err := TOTPCode(secret, t)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpStep - Vaqtga mos TOTP oralig'i raqami
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod/time.Second)
+}
+
+// TOTPURI - Authenticator ilovalari uchun otpauth:// manzil (QR kod sifatida ko'rsatiladi)
+func TOTPURI(issuer, account, secret string) string {
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", issuer)
+	values.Set("digits", fmt.Sprint(totpDigits))
+	values.Set("period", fmt.Sprint(int(totpPeriod/time.Second)))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + values.Encode()
+}
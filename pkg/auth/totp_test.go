@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret - RFC 6238 test kaliti ("12345678901234567890") base32 ko'rinishida
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 B ilovasidagi SHA1 qiymatlari (oxirgi 6 raqam)
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode(%d) xatolik: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(%d) = %s, kutilgan %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPCodeInvalidSecret(t *testing.T) {
+	if _, err := TOTPCode("not base32!", time.Now()); err == nil {
+		t.Error("noto'g'ri kalit uchun xatolik kutilgan")
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0) // 37037037-oraliq, kod 050471
+	step := now.Unix() / 30
+
+	code := func(offset time.Duration) string {
+		c, err := TOTPCode(rfcSecret, now.Add(offset))
+		if err != nil {
+			t.Fatalf("TOTPCode xatolik: %v", err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"joriy kod", rfcSecret, code(0), 0, step, true},
+		{"bo'shliqlar bilan", rfcSecret, " " + code(0) + " ", 0, step, true},
+		{"oldingi oraliq", rfcSecret, code(-30 * time.Second), 0, step - 1, true},
+		{"keyingi oraliq", rfcSecret, code(30 * time.Second), 0, step + 1, true},
+		{"ikki oraliq oldin", rfcSecret, code(-60 * time.Second), 0, 0, false},
+		{"ikki oraliq keyin", rfcSecret, code(60 * time.Second), 0, 0, false},
+		{"noto'g'ri kod", rfcSecret, "000000", 0, 0, false},
+		{"qisqa kod", rfcSecret, "50471", 0, 0, false},
+		{"bo'sh kod", rfcSecret, "", 0, 0, false},
+		{"noto'g'ri kalit", "not base32!", code(0), 0, 0, false},
+		{"kod qayta ishlatilgan", rfcSecret, code(0), step, 0, false},
+		{"eski oraliq qayta ishlatilgan", rfcSecret, code(-30 * time.Second), step, 0, false},
+		{"oldingi oraliqdan keyingi kod", rfcSecret, code(30 * time.Second), step, step + 1, true},
+		{"oldingi oraliq saqlangan, joriy kod", rfcSecret, code(0), step - 1, step, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := ValidateTOTP(tt.secret, tt.code, now, tt.lastStep)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("ValidateTOTP() = %d, %v, kutilgan %d, %v", gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret xatolik: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("kalit uzunligi = %d, kutilgan 32", len(secret))
+	}
+
+	code, err := TOTPCode(secret, time.Now())
+	if err != nil {
+		t.Fatalf("yaratilgan kalit bilan TOTPCode xatolik: %v", err)
+	}
+	if _, ok := ValidateTOTP(secret, code, time.Now(), 0); !ok {
+		t.Error("yaratilgan kalit kodi qabul qilinmadi")
+	}
+}
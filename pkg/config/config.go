@@ -10,11 +10,17 @@ import (
 
 // Configuration - asosiy konfiguratsiya strukturasi
 type Configuration struct {
-	Server    ServerConfig    `yaml:"server"`
-	API       APIConfig       `yaml:"api"`
-	Wireguard WireguardConfig `yaml:"wireguard"`
-	Database  DatabaseConfig  `yaml:"database"`
-	Security  SecurityConfig  `yaml:"security"`
+	Server     ServerConfig     `yaml:"server"`
+	API        APIConfig        `yaml:"api"`
+	Wireguard  WireguardConfig  `yaml:"wireguard"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Security   SecurityConfig   `yaml:"security"`
+	Reconciler ReconcilerConfig `yaml:"reconciler"`
+	Monitor    MonitorConfig    `yaml:"monitor"`
+	Quota      QuotaConfig      `yaml:"quota"`
+	Metrics    MetricsConfig    `yaml:"metrics"`
+	Webhooks   WebhooksConfig   `yaml:"webhooks"`
+	Auth       AuthConfig       `yaml:"auth"`
 }
 
 // ServerConfig - server konfiguratsiyasi
@@ -27,16 +33,35 @@ type ServerConfig struct {
 
 // APIConfig - API konfiguratsiyasi
 type APIConfig struct {
-	Port  int    `yaml:"port"`
-	Token string `yaml:"token"`
+	Bind  string    `yaml:"bind"` // Tinglanadigan manzil (bo'sh bo'lsa barcha interfeyslar)
+	Port  int       `yaml:"port"`
+	Token string    `yaml:"token"`
+	TLS   TLSConfig `yaml:"tls"`
+}
+
+// TLSConfig - API uchun HTTPS konfiguratsiyasi (sertifikat fayllari o'zgarganda qayta yuklanadi)
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file"` // Bo'sh bo'lsa API oddiy HTTP orqali ishlaydi
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"` // Ko'rsatilsa mijoz sertifikati talab qilinadi (mutual TLS)
 }
 
 // WireguardConfig - Wireguard konfiguratsiyasi
 type WireguardConfig struct {
-	DNS                 string `yaml:"dns"`
-	AllowedIPs          string `yaml:"allowed_ips"`
-	PersistentKeepalive int    `yaml:"persistent_keepalive"`
-	ServerPublicKeyPath string `yaml:"server_public_key_path"`
+	DNS                 string                `yaml:"dns"`
+	AllowedIPs          string                `yaml:"allowed_ips"`
+	PersistentKeepalive int                   `yaml:"persistent_keepalive"`
+	ServerPublicKeyPath string                `yaml:"server_public_key_path"`
+	Backend             string                `yaml:"backend"`       // exec, netlink yoki memory
+	Pools               map[string]PoolConfig `yaml:"pools"`         // Client turi (normal, vip) -> pool
+	IPQuarantine        *int                  `yaml:"ip_quarantine"` // O'chirilgan client manzili qayta berilguncha kutish (soatlarda), 0 = karantinsiz, ko'rsatilmasa 24 soat
+}
+
+// PoolConfig - Client turi uchun IP manzillar pooli konfiguratsiyasi
+type PoolConfig struct {
+	CIDR     string   `yaml:"cidr"`
+	IPv6CIDR string   `yaml:"ipv6_cidr"` // Ixtiyoriy: ULA yoki global IPv6 prefix (masalan fd00:7::/64)
+	Reserved []string `yaml:"reserved"`  // IP, CIDR yoki "10.7.0.1-10.7.0.9" ko'rinishidagi oraliqlar
 }
 
 // DatabaseConfig - Database konfiguratsiyasi
@@ -46,17 +71,119 @@ type DatabaseConfig struct {
 
 // SecurityConfig - Xavfsizlik konfiguratsiyasi
 type SecurityConfig struct {
-	IPBlocker IPBlockerConfig `yaml:"ip_blocker"`
+	TrustedProxies []string        `yaml:"trusted_proxies"` // Client IP sarlavhalari qabul qilinadigan proxy IP/CIDR lari
+	RealIPHeader   string          `yaml:"real_ip_header"`  // X-Forwarded-For yoki X-Real-IP
+	ProxyProtocol  bool            `yaml:"proxy_protocol"`  // Ishonchli proxylardan PROXY protocol (v1/v2) sarlavhasini qabul qilish
+	IPBlocker      IPBlockerConfig `yaml:"ip_blocker"`
+	RateLimit      RateLimitConfig `yaml:"rate_limit"`
+}
+
+// RateLimitConfig - So'rov egasi (API kaliti, foydalanuvchi, token) bo'yicha so'rovlar chastotasini cheklash konfiguratsiyasi
+type RateLimitConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	Read         RateLimitRule `yaml:"read"`          // Client va server ma'lumotlarini o'qish
+	Write        RateLimitRule `yaml:"write"`         // Clientlarni o'zgartirish
+	Admin        RateLimitRule `yaml:"admin"`         // Administrator endpointlari
+	ClientCreate RateLimitRule `yaml:"client_create"` // POST /api/client (write chegarasiga qo'shimcha)
+	Login        RateLimitRule `yaml:"login"`         // POST /api/auth/login, IP manzil bo'yicha (enabled va IP blokerdan qat'i nazar)
+}
+
+// RateLimitRule - Token bucket chegarasi
+type RateLimitRule struct {
+	Rate  int `yaml:"rate"`  // Minutiga so'rovlar, 0 = cheklanmagan
+	Burst int `yaml:"burst"` // Ketma-ket yuborish mumkin bo'lgan so'rovlar (0 = rate)
 }
 
 // IPBlockerConfig - IP bloklash konfiguratsiyasi
 type IPBlockerConfig struct {
-	Enabled       bool   `yaml:"enabled"`
-	MaxAttempts   int    `yaml:"max_attempts"`
-	BlockDuration int    `yaml:"block_duration"` // Minutlarda
-	LogFilePath   string `yaml:"log_file_path"`
+	Enabled       bool           `yaml:"enabled"`
+	MaxAttempts   int            `yaml:"max_attempts"`   // Oyna ichidagi maksimal xato urinishlar
+	FailureWindow int            `yaml:"failure_window"` // Minutlarda, 0 = urinishlar eskirmaydi
+	BlockDuration int            `yaml:"block_duration"` // Minutlarda (ban_durations bo'sh bo'lsa)
+	BanDurations  []int          `yaml:"ban_durations"`  // Ketma-ket bloklar muddati minutlarda, 0 = muddatsiz
+	OffenseTTL    int            `yaml:"offense_ttl"`    // Soatlarda, shu vaqt ichida qayta bloklanmasa muddat boshidan hisoblanadi
+	Subnet        SubnetConfig   `yaml:"subnet"`
+	LogFilePath   string         `yaml:"log_file_path"`
+	Firewall      FirewallConfig `yaml:"firewall"`
+	Allowlist     []string       `yaml:"allowlist"` // Hech qachon bloklanmaydigan CIDR lar
+	Denylist      []string       `yaml:"denylist"`  // Har doim bloklangan CIDR lar
+}
+
+// SubnetConfig - Bitta subnetdagi turli manzillardan kelgan xato urinishlarni birga hisoblash konfiguratsiyasi
+type SubnetConfig struct {
+	MaxAttempts int `yaml:"max_attempts"` // Oyna ichidagi maksimal xato urinishlar, 0 = o'chirilgan
+	IPv4Prefix  int `yaml:"ipv4_prefix"`  // Masalan 24
+	IPv6Prefix  int `yaml:"ipv6_prefix"`  // Masalan 64
+}
+
+// FirewallConfig - Bloklangan IP manzillarni firewall darajasida to'xtatish konfiguratsiyasi
+type FirewallConfig struct {
+	Backend string `yaml:"backend"` // nftables, ipset yoki bo'sh (faqat HTTP 429)
+	Table   string `yaml:"table"`   // nftables jadvali nomi
+	Set     string `yaml:"set"`     // Set nomi (IPv6 uchun "_v6" qo'shimchasi bilan)
+}
+
+// ReconcilerConfig - Database va interface o'rtasidagi farqlarni tekshirish konfiguratsiyasi
+type ReconcilerConfig struct {
+	Enabled    bool `yaml:"enabled"`
+	Interval   int  `yaml:"interval"`    // Minutlarda
+	AutoRepair bool `yaml:"auto_repair"` // false bo'lsa, faqat hisobot yoziladi
+}
+
+// MonitorConfig - Peer statistikasini davriy o'qish konfiguratsiyasi
+type MonitorConfig struct {
+	Interval int `yaml:"interval"` // Soniyalarda
+}
+
+// QuotaConfig - Traffic kvotasi konfiguratsiyasi
+type QuotaConfig struct {
+	Policy   string `yaml:"policy"`    // Kvota tugaganda: suspend yoki delete
+	ResetDay int    `yaml:"reset_day"` // Oylik kvota qayta tiklanadigan kun (1-28)
+}
+
+// MetricsConfig - Prometheus metrikalari konfiguratsiyasi
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Token   string `yaml:"token"` // Bo'sh bo'lsa /metrics tokensiz ochiq (startda ogohlantirish yoziladi)
 }
 
+// WebhooksConfig - Tashqi tizimlarga hodisalarni yuborish konfiguratsiyasi
+type WebhooksConfig struct {
+	ExpiringSoon int               `yaml:"expiring_soon"` // Muddati tugashidan necha soat oldin ogohlantirish
+	MaxAttempts  int               `yaml:"max_attempts"`  // Yetkazish urinishlari soni
+	Endpoints    []WebhookEndpoint `yaml:"endpoints"`
+}
+
+// WebhookEndpoint - Bitta webhook manzili
+type WebhookEndpoint struct {
+	URL    string   `yaml:"url"`
+	Secret string   `yaml:"secret"` // HMAC-SHA256 imzo uchun kalit
+	Events []string `yaml:"events"` // Bo'sh bo'lsa barcha webhook hodisalari yuboriladi
+}
+
+// AuthConfig - Web interfeys foydalanuvchilari autentifikatsiyasi konfiguratsiyasi
+type AuthConfig struct {
+	SessionTTL   int       `yaml:"session_ttl"`   // Sessiya muddati, soatlarda
+	CookieSecure bool      `yaml:"cookie_secure"` // Sessiya cookiesi faqat HTTPS orqali yuboriladi
+	JWT          JWTConfig `yaml:"jwt"`
+}
+
+// JWTConfig - Tashqi identity provider (OIDC) tokenlarini qabul qilish konfiguratsiyasi
+type JWTConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	Issuer      string            `yaml:"issuer"`       // Kutilgan "iss" claim
+	Audience    string            `yaml:"audience"`     // Kutilgan "aud" claim (bo'sh bo'lsa tekshirilmaydi)
+	JWKSURL     string            `yaml:"jwks_url"`     // Ochiq kalitlar manzili
+	KeyFile     string            `yaml:"key_file"`     // Yoki PEM formatdagi statik ochiq kalit
+	JWKSRefresh int               `yaml:"jwks_refresh"` // JWKS ni qayta yuklash oralig'i (minutlarda)
+	ScopeClaim  string            `yaml:"scope_claim"`  // Ruxsatlar claimi (satr yoki ro'yxat)
+	RoleClaim   string            `yaml:"role_claim"`   // Rollar yoki guruhlar claimi
+	RoleMap     map[string]string `yaml:"role_map"`     // Identity provider guruhi -> viewer/operator/admin
+}
+
+// defaultIPQuarantine - ip_quarantine ko'rsatilmaganda ishlatiladigan karantin muddati (soatlarda)
+var defaultIPQuarantine = 24
+
 // Config - global konfiguratsiya o'zgaruvchisi
 var Config Configuration
 
@@ -88,24 +215,94 @@ func CreateDefaultConfig(configPath string) error {
 			Debug:     false,
 		},
 		API: APIConfig{
+			Bind:  "",
 			Port:  8080,
 			Token: "secure-token-change-me",
+			TLS: TLSConfig{
+				CertFile:     "",
+				KeyFile:      "",
+				ClientCAFile: "",
+			},
 		},
 		Wireguard: WireguardConfig{
 			DNS:                 "1.1.1.1, 8.8.8.8",
 			AllowedIPs:          "0.0.0.0/0, ::/0",
 			PersistentKeepalive: 25,
 			ServerPublicKeyPath: "/etc/wireguard/server_public.key",
+			Backend:             "exec",
+			Pools: map[string]PoolConfig{
+				"normal": {CIDR: "10.7.0.0/16", Reserved: []string{"10.7.0.1"}},
+				"vip":    {CIDR: "10.77.0.0/16", Reserved: []string{"10.77.0.1"}},
+			},
+			IPQuarantine: &defaultIPQuarantine,
 		},
 		Database: DatabaseConfig{
 			Path: "./data/wireguard.db",
 		},
 		Security: SecurityConfig{
+			TrustedProxies: []string{},
+			RealIPHeader:   "X-Forwarded-For",
+			ProxyProtocol:  false,
 			IPBlocker: IPBlockerConfig{
 				Enabled:       true,
 				MaxAttempts:   3,
+				FailureWindow: 15,
 				BlockDuration: 60, // 60 minut (1 soat)
-				LogFilePath:   "./logs/auth_failures.log",
+				BanDurations:  []int{60, 360, 1440, 0},
+				OffenseTTL:    168, // 1 hafta
+				Subnet: SubnetConfig{
+					MaxAttempts: 0,
+					IPv4Prefix:  24,
+					IPv6Prefix:  64,
+				},
+				LogFilePath: "./logs/auth_failures.log",
+				Firewall: FirewallConfig{
+					Backend: "",
+					Table:   "wireguard_api",
+					Set:     "blocked",
+				},
+				Allowlist: []string{},
+				Denylist:  []string{},
+			},
+			RateLimit: RateLimitConfig{
+				Enabled:      true,
+				Read:         RateLimitRule{Rate: 600, Burst: 120},
+				Write:        RateLimitRule{Rate: 120, Burst: 30},
+				Admin:        RateLimitRule{Rate: 60, Burst: 20},
+				ClientCreate: RateLimitRule{Rate: 10, Burst: 5},
+				Login:        RateLimitRule{Rate: 10, Burst: 5},
+			},
+		},
+		Reconciler: ReconcilerConfig{
+			Enabled:    true,
+			Interval:   5,
+			AutoRepair: false,
+		},
+		Monitor: MonitorConfig{
+			Interval: 60,
+		},
+		Quota: QuotaConfig{
+			Policy:   "suspend",
+			ResetDay: 1,
+		},
+		Metrics: MetricsConfig{
+			Enabled: false, // Metrikalarda client tavsiflari bor, yoqishdan oldin token sozlang
+			Token:   "",
+		},
+		Webhooks: WebhooksConfig{
+			ExpiringSoon: 24,
+			MaxAttempts:  8,
+			Endpoints:    []WebhookEndpoint{},
+		},
+		Auth: AuthConfig{
+			SessionTTL:   12,
+			CookieSecure: false,
+			JWT: JWTConfig{
+				Enabled:     false,
+				JWKSRefresh: 60,
+				ScopeClaim:  "scope",
+				RoleClaim:   "roles",
+				RoleMap:     map[string]string{},
 			},
 		},
 	}
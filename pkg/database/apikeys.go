@@ -0,0 +1,133 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wireguard-vpn-client-creater/pkg/models"
+)
+
+// APIKeyPrefix - Barcha API kalitlari shu prefiks bilan boshlanadi
+const APIKeyPrefix = "wgk_"
+
+// apiKeyTouchInterval - last_used_at ni har so'rovda emas, shu oraliqda bir marta yangilash
+const apiKeyTouchInterval = time.Minute
+
+// ErrAPIKeyNotFound - Kalit topilmadi, bekor qilingan yoki muddati o'tgan
+var ErrAPIKeyNotFound = errors.New("API kaliti topilmadi")
+
+// CreateAPIKey - Yangi API kaliti yaratish. Kalitning o'zi faqat shu yerda qaytariladi.
+// limits dagi 0 qiymatli guruhlar uchun konfiguratsiyadagi chegaralar ishlatiladi.
+func CreateAPIKey(name, scopes, createdBy string, expiresAt *time.Time, limits models.APIKeyLimits) (string, *models.APIKey, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, fmt.Errorf("kalit yaratishda xatolik: %v", err)
+	}
+	token := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	key := &models.APIKey{
+		Name:         name,
+		Prefix:       token[:len(APIKeyPrefix)+6],
+		KeyHash:      HashAPIKey(token),
+		Scopes:       models.ScopeList(scopes),
+		APIKeyLimits: limits,
+		CreatedBy:    createdBy,
+		ExpiresAt:    expiresAt,
+	}
+	if err := DB.Create(key).Error; err != nil {
+		return "", nil, err
+	}
+
+	return token, key, nil
+}
+
+// HashAPIKey - Kalitning databasega saqlanadigan xeshi
+func HashAPIKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetActiveAPIKey - Kalit bo'yicha faol (bekor qilinmagan, muddati o'tmagan) API kalitini olish
+func GetActiveAPIKey(token string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := DB.Where("key_hash = ? AND revoked_at IS NULL", HashAPIKey(token)).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now()) {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	return &key, nil
+}
+
+// TouchAPIKey - Kalit oxirgi ishlatilgan vaqtni yangilash
+func TouchAPIKey(key *models.APIKey) {
+	now := time.Now()
+	if key.LastUsedAt != nil && now.Sub(*key.LastUsedAt) < apiKeyTouchInterval {
+		return
+	}
+
+	DB.Model(&models.APIKey{}).Where("id = ?", key.ID).Update("last_used_at", now)
+	key.LastUsedAt = &now
+}
+
+// GetAllAPIKeys - Barcha API kalitlarini olish
+func GetAllAPIKeys() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := DB.Order("id").Find(&keys).Error
+	return keys, err
+}
+
+// RevokeAPIKey - API kalitini bekor qilish
+func RevokeAPIKey(id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := DB.Where("id = ? AND revoked_at IS NULL", id).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	key.RevokedAt = &now
+	if err := DB.Model(&key).Update("revoked_at", now).Error; err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// UpdateAPIKeyLimits - Faol API kalitining so'rovlar chegaralarini o'zgartirish
+func UpdateAPIKeyLimits(id uint, limits models.APIKeyLimits) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := DB.Where("id = ? AND revoked_at IS NULL", id).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+
+	key.APIKeyLimits = limits
+	if err := DB.Model(&key).Updates(map[string]interface{}{
+		"read_limit":          limits.ReadLimit,
+		"write_limit":         limits.WriteLimit,
+		"admin_limit":         limits.AdminLimit,
+		"client_create_limit": limits.ClientCreateLimit,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"encoding/json"
+	"log"
+	"reflect"
+	"time"
+
+	"wireguard-vpn-client-creater/pkg/models"
+)
+
+// Audit jurnali uchun amallar
+const (
+	AuditClientCreate   = "client.create"
+	AuditClientDelete   = "client.delete"
+	AuditClientExpire   = "client.expire"
+	AuditClientSuspend  = "client.suspend"
+	AuditClientResume   = "client.resume"
+	AuditLifetimeUpdate = "client.lifetime.update"
+	AuditQuotaUpdate    = "client.quota.update"
+	AuditQuotaEnforce   = "client.quota.enforce"
+	AuditQuotaReset     = "client.quota.reset"
+	AuditAPIKeyCreate   = "apikey.create"
+	AuditAPIKeyRevoke   = "apikey.revoke"
+	AuditAPIKeyUpdate   = "apikey.update"
+	AuditUserCreate     = "user.create"
+	AuditUserUpdate     = "user.update"
+	AuditUserDelete     = "user.delete"
+	AuditUserLogin      = "user.login"
+	AuditUserLogout     = "user.logout"
+	AuditTOTPEnable     = "user.totp.enable"
+	AuditTOTPDisable    = "user.totp.disable"
+	AuditIPBlock        = "security.block"
+	AuditIPUnblock      = "security.unblock"
+	AuditAccessCreate   = "security.access.create"
+	AuditAccessDelete   = "security.access.delete"
+)
+
+// Tizim jarayonlari uchun actorlar
+const (
+	ActorExpiration = "system:expiration"
+	ActorQuota      = "system:quota"
+)
+
+// auditIgnoredFields - Jurnalga yozilmaydigan maydonlar (maxfiy yoki ma'nosiz)
+var auditIgnoredFields = map[string]bool{
+	"private_key":   true,
+	"preshared_key": true,
+	"UpdatedAt":     true,
+	"DeletedAt":     true,
+}
+
+// AuditChange - Bitta maydonning oldingi va keyingi qiymati
+type AuditChange struct {
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// AuditFilter - Audit jurnalini qidirish parametrlari
+type AuditFilter struct {
+	Actor    string
+	Action   string
+	ClientID *uint
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+	Offset   int
+}
+
+// RecordAudit - Client ustidagi amalni audit jurnaliga yozish. before yoki after nil bo'lishi mumkin (yaratish/o'chirish).
+// Jurnalga yozishdagi xatolik asosiy amalni to'xtatmaydi, faqat logga yoziladi.
+func RecordAudit(actor, sourceIP, action string, clientID uint, before, after *models.WireguardClient) {
+	RecordAuditDetails(actor, sourceIP, action, &clientID, diffClients(before, after))
+}
+
+// RecordAuditDetails - Clientga bog'liq bo'lmagan amalni (masalan API kalitlari) jurnalga yozish
+func RecordAuditDetails(actor, sourceIP, action string, clientID *uint, details interface{}) {
+	changes, err := json.Marshal(details)
+	if err != nil {
+		log.Printf("Audit: o'zgarishlarni JSON ga o'tkazishda xatolik: %v", err)
+	}
+
+	entry := &models.AuditLog{
+		Actor:    actor,
+		SourceIP: sourceIP,
+		Action:   action,
+		ClientID: clientID,
+		Changes:  models.RawJSON(changes),
+	}
+	if err := DB.Create(entry).Error; err != nil {
+		log.Printf("Audit: %s amalini yozishda xatolik: %v", action, err)
+	}
+}
+
+// GetAuditLogs - Audit jurnalini filtrlar bo'yicha eng yangisidan boshlab olish
+func GetAuditLogs(filter AuditFilter) ([]models.AuditLog, int64, error) {
+	query := DB.Model(&models.AuditLog{})
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ClientID != nil {
+		query = query.Where("client_id = ?", *filter.ClientID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", filter.To.UTC())
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []models.AuditLog
+	err := query.Order("id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&entries).Error
+	return entries, total, err
+}
+
+// diffClients - Ikki client holati orasidagi farq qiluvchi maydonlar
+func diffClients(before, after *models.WireguardClient) map[string]AuditChange {
+	beforeFields := clientFields(before)
+	afterFields := clientFields(after)
+
+	changes := make(map[string]AuditChange)
+	for name, value := range beforeFields {
+		if !reflect.DeepEqual(value, afterFields[name]) {
+			changes[name] = AuditChange{Before: value, After: afterFields[name]}
+		}
+	}
+	for name, value := range afterFields {
+		if _, exists := beforeFields[name]; !exists {
+			changes[name] = AuditChange{After: value}
+		}
+	}
+
+	return changes
+}
+
+// clientFields - Clientni JSON maydonlari bo'yicha mapga o'tkazish
+func clientFields(client *models.WireguardClient) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if client == nil {
+		return fields
+	}
+
+	data, err := json.Marshal(client)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+
+	for name := range auditIgnoredFields {
+		delete(fields, name)
+	}
+	return fields
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"wireguard-vpn-client-creater/pkg/models"
+	"wireguard-vpn-client-creater/pkg/security"
+)
+
+// Ruxsat va taqiq ro'yxatlari xatoliklari
+var (
+	ErrAccessRuleNotFound = errors.New("ro'yxat yozuvi topilmadi")
+	ErrAccessRuleExists   = errors.New("bu CIDR ro'yxatda allaqachon bor")
+)
+
+// IPBlockStore - IP bloklash tizimi bloklarini databasega saqlovchi ombor (security.BlockStore)
+type IPBlockStore struct{}
+
+// LoadBlocks - Barcha saqlangan bloklarni olish. Muddati tugaganlari ham qaytariladi,
+// chunki takroriy blok muddati ular bo'yicha hisoblanadi.
+func (IPBlockStore) LoadBlocks() ([]security.Block, error) {
+	var rows []models.BlockedIP
+	if err := DB.Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	blocks := make([]security.Block, 0, len(rows))
+	for _, row := range rows {
+		block := security.Block{
+			IP:        row.IP,
+			BlockedAt: row.BlockedAt,
+			Reason:    row.Reason,
+			Offense:   row.Offense,
+		}
+		if row.ExpiresAt != nil {
+			block.ExpiresAt = *row.ExpiresAt
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// SaveBlock - Blokni saqlash (shu IP uchun oldingi blok almashtiriladi)
+func (IPBlockStore) SaveBlock(block security.Block) error {
+	row := &models.BlockedIP{
+		IP:        block.IP,
+		BlockedAt: block.BlockedAt.UTC(),
+		Reason:    block.Reason,
+		Offense:   block.Offense,
+	}
+	if !block.Permanent() {
+		expiresAt := block.ExpiresAt.UTC()
+		row.ExpiresAt = &expiresAt
+	}
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "ip"}},
+		DoUpdates: clause.AssignmentColumns([]string{"blocked_at", "expires_at", "reason", "offense"}),
+	}).Create(row).Error
+}
+
+// DeleteBlock - IP manzil blokini o'chirish
+func (IPBlockStore) DeleteBlock(ip string) error {
+	return DB.Where("ip = ?", ip).Delete(&models.BlockedIP{}).Error
+}
+
+// GetIPAccessRules - Databasedagi ruxsat va taqiq ro'yxatlari yozuvlari
+func GetIPAccessRules() ([]models.IPAccessRule, error) {
+	var rules []models.IPAccessRule
+	err := DB.Order("id").Find(&rules).Error
+	return rules, err
+}
+
+// CreateIPAccessRule - Ro'yxatga yangi CIDR qo'shish
+func CreateIPAccessRule(rule *models.IPAccessRule) error {
+	var count int64
+	if err := DB.Model(&models.IPAccessRule{}).Where("list = ? AND cidr = ?", rule.List, rule.CIDR).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrAccessRuleExists
+	}
+	return DB.Create(rule).Error
+}
+
+// DeleteIPAccessRule - Ro'yxat yozuvini o'chirish
+func DeleteIPAccessRule(id uint) (*models.IPAccessRule, error) {
+	var rule models.IPAccessRule
+	err := DB.First(&rule, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAccessRuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &rule, DB.Delete(&rule).Error
+}
@@ -4,7 +4,6 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"gorm.io/driver/sqlite"
@@ -12,6 +11,7 @@ import (
 	"gorm.io/gorm/logger"
 
 	"wireguard-vpn-client-creater/pkg/config"
+	"wireguard-vpn-client-creater/pkg/events"
 	"wireguard-vpn-client-creater/pkg/models"
 	"wireguard-vpn-client-creater/pkg/wireguard"
 )
@@ -39,12 +39,18 @@ func InitDB(dbPath string) (*gorm.DB, error) {
 	}
 
 	// Modellarni migrate qilish
-	err = db.AutoMigrate(&models.WireguardClient{})
+	err = db.AutoMigrate(&models.WireguardClient{}, &models.IPLease{}, &models.RollbackFailure{}, &models.TrafficSample{}, &models.ClientSession{}, &models.WebhookDelivery{}, &models.AuditLog{}, &models.APIKey{}, &models.AdminUser{}, &models.UserSession{}, &models.BlockedIP{}, &models.IPAccessRule{})
 	if err != nil {
 		return nil, err
 	}
 
 	DB = db
+
+	// Eski clientlar uchun IP manzil yozuvlarini yaratish
+	if err := backfillLeases(); err != nil {
+		return nil, err
+	}
+
 	log.Println("Database initialized at", dbPath)
 	return db, nil
 }
@@ -75,9 +81,16 @@ func GetClientByAddress(address string) (models.WireguardClient, error) {
 	return client, err
 }
 
-// UpdateClient - Clientni yangilash
-func UpdateClient(client *models.WireguardClient) error {
-	return DB.Save(client).Error
+// UpdateClient - Clientning faqat berilgan ustunlarini yangilash.
+// Butun qatorni saqlash traffic monitor parallel yozayotgan used_bytes, last_rx_bytes/last_tx_bytes
+// va last_connected qiymatlarini eskisi bilan almashtirib yuborardi.
+func UpdateClient(client *models.WireguardClient, columns ...string) error {
+	return updateClientColumns(DB, client, columns)
+}
+
+// updateClientColumns - Berilgan ustunlarni (va updated_at ni) tranzaksiya yoki DB orqali yangilash
+func updateClientColumns(db *gorm.DB, client *models.WireguardClient, columns []string) error {
+	return db.Model(client).Select(append(columns, "updated_at")).Updates(client).Error
 }
 
 // DeleteClient - Clientni o'chirish
@@ -106,6 +119,39 @@ func CheckExpiredClients() ([]models.WireguardClient, error) {
 	return expiredClients, err
 }
 
+// GetClientsExpiringSoon - Muddati window ichida tugaydigan va hali ogohlantirilmagan clientlarni olish
+func GetClientsExpiringSoon(window time.Duration) ([]models.WireguardClient, error) {
+	var clients []models.WireguardClient
+	now := time.Now()
+	err := DB.Where("expires_at IS NOT NULL AND expires_at >= ? AND expires_at < ? AND expiry_notified_at IS NULL",
+		now, now.Add(window)).Find(&clients).Error
+	return clients, err
+}
+
+// MarkExpiryNotified - Client muddati tugashi haqida ogohlantirilganini belgilash
+func MarkExpiryNotified(clientID uint) error {
+	return DB.Model(&models.WireguardClient{}).Where("id = ?", clientID).
+		Update("expiry_notified_at", time.Now()).Error
+}
+
+// NotifyExpiringClients - Muddati window ichida tugaydigan clientlar uchun bir martalik hodisa yuborish
+func NotifyExpiringClients(window time.Duration) error {
+	clients, err := GetClientsExpiringSoon(window)
+	if err != nil {
+		return err
+	}
+
+	for i := range clients {
+		if err := MarkExpiryNotified(clients[i].ID); err != nil {
+			log.Printf("Xatolik: client %d ogohlantirishini belgilashda: %v", clients[i].ID, err)
+			continue
+		}
+		events.PublishClient(events.ClientExpiringSoon, &clients[i])
+	}
+
+	return nil
+}
+
 // DeleteExpiredClients - Muddati o'tgan clientlarni o'chirish
 func DeleteExpiredClients() error {
 	// Muddati o'tgan clientlarni topish
@@ -119,42 +165,15 @@ func DeleteExpiredClients() error {
 		log.Printf("O'chirish: muddati o'tgan client: %d - %s (muddati tugagan: %s)",
 			client.ID, client.Description, client.ExpiresAt.Format(time.RFC3339))
 
-		// Wireguard konfiguratsiyasidan peer ni o'chirish
-		if err := wireguard.RemovePeerFromServer(client.PublicKey); err != nil {
-			log.Printf("Xatolik: Wireguard konfiguratsiyasidan client %d ni o'chirishda: %v", client.ID, err)
-			continue
-		}
-
-		// Databasedan to'liq o'chirish (hard delete)
-		if err := DB.Unscoped().Delete(&client).Error; err != nil {
-			log.Printf("Xatolik: Databasedan client %d ni o'chirishda: %v", client.ID, err)
+		// Peerni interfacedan va clientni databasedan o'chirish (hard delete)
+		if err := DeprovisionClient(&client, events.ClientExpired, wireguard.Current); err != nil {
+			log.Printf("Xatolik: client %d ni o'chirishda: %v", client.ID, err)
 			continue
 		}
 
+		RecordAudit(ActorExpiration, "", AuditClientExpire, client.ID, &client, nil)
 		log.Printf("Client %d muvaffaqiyatli o'chirildi", client.ID)
 	}
 
 	return nil
 }
-
-// GetUsedIPAddresses - Ishlatilayotgan IP manzillarni olish
-func GetUsedIPAddresses(subnetPrefix string) ([]string, error) {
-	var clients []models.WireguardClient
-	var addresses []string
-
-	// Berilgan subnet prefixga mos IP manzillarni olish
-	// Unscoped() orqali o'chirilgan clientlarni ham qo'shib olish
-	err := DB.Unscoped().Where("address LIKE ?", subnetPrefix+"%").Find(&clients).Error
-	if err != nil {
-		return nil, err
-	}
-
-	// IP manzillarni saqlash
-	for _, client := range clients {
-		// CIDR notatsiyasini olib tashlash (/32)
-		ipWithoutCIDR := strings.Split(client.Address, "/")[0]
-		addresses = append(addresses, ipWithoutCIDR)
-	}
-
-	return addresses, nil
-}
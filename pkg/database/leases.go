@@ -0,0 +1,325 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wireguard-vpn-client-creater/pkg/config"
+	"wireguard-vpn-client-creater/pkg/ipam"
+	"wireguard-vpn-client-creater/pkg/models"
+)
+
+// allocMu - IP manzil ajratish va client yaratishni ketma-ket bajarish uchun mutex.
+// SQLite bitta yozuvchini qo'llab-quvvatlaydi, unikal index esa oxirgi himoya vazifasini bajaradi.
+var allocMu sync.Mutex
+
+// CreateClient - Clientga pooldan IP manzil ajratib, bitta tranzaksiyada databasega saqlash.
+// beforeCommit berilgan bo'lsa, u tranzaksiya ichida chaqiriladi va xatolik qaytarsa
+// manzil ham, client ham saqlanmaydi.
+func CreateClient(client *models.WireguardClient, beforeCommit func(*models.WireguardClient) error) error {
+	pool, err := ipam.PoolFor(client.Type)
+	if err != nil {
+		return err
+	}
+
+	allocMu.Lock()
+	defer allocMu.Unlock()
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		lease, err := allocateLease(tx, pool)
+		if err != nil {
+			return err
+		}
+
+		client.Address = lease.Address
+		client.AddressV6 = lease.AddressV6
+		if err := tx.Create(client).Error; err != nil {
+			return fmt.Errorf("clientni saqlashda xatolik: %v", err)
+		}
+
+		lease.ClientID = &client.ID
+		if err := tx.Save(lease).Error; err != nil {
+			return fmt.Errorf("IP manzilni band qilishda xatolik: %v", err)
+		}
+
+		if beforeCommit != nil {
+			return beforeCommit(client)
+		}
+		return nil
+	})
+}
+
+// allocateLease - Pooldagi keyingi bo'sh manzilni topish (indexlangan so'rovlar orqali).
+// Avval karantin muddati o'tgan bo'shatilgan manzillar qayta ishlatiladi.
+func allocateLease(tx *gorm.DB, pool *ipam.Pool) (*models.IPLease, error) {
+	lease, err := reclaimLease(tx, pool)
+	if err != nil {
+		return nil, err
+	}
+	if lease != nil {
+		return lease, nil
+	}
+
+	// Eng katta band qilingan tartib raqamidan keyingi manzil
+	var maxIndex *uint32
+	if err := tx.Model(&models.IPLease{}).Where("pool = ?", pool.Name).
+		Select("MAX(host_index)").Scan(&maxIndex).Error; err != nil {
+		return nil, fmt.Errorf("band qilingan manzillarni olishda xatolik: %v", err)
+	}
+
+	from := pool.FirstIndex()
+	if maxIndex != nil {
+		from = *maxIndex + 1
+	}
+
+	index, ok := uint32(0), false
+	if maxIndex == nil || *maxIndex < pool.LastIndex() {
+		index, ok = pool.NextUsableIndex(from)
+	}
+
+	// Pool oxiriga yetilgan bo'lsa, bo'sh qolgan oraliqlarni qidirish
+	if !ok {
+		index, ok = findGap(tx, pool)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s (%s)", ipam.ErrPoolExhausted, pool.Name, pool.Prefix)
+	}
+
+	address, addressV6 := pool.AddressesAt(index)
+	return &models.IPLease{
+		Pool:      pool.Name,
+		HostIndex: index,
+		Address:   address,
+		AddressV6: addressV6,
+	}, nil
+}
+
+// reclaimBatchSize - Bo'shatilgan manzillarni databasedan o'qish bo'lagi
+const reclaimBatchSize = 50
+
+// reclaimLease - Karantin muddati o'tgan eng eski bo'shatilgan manzilni olish.
+// Reserved bo'lib qolgan manzillar o'tkazib yuboriladi, shuning uchun nomzodlar bo'laklab oxirigacha ko'riladi.
+func reclaimLease(tx *gorm.DB, pool *ipam.Pool) (*models.IPLease, error) {
+	cutoff := time.Now().Add(-QuarantineDuration())
+
+	for offset := 0; ; offset += reclaimBatchSize {
+		var candidates []models.IPLease
+		err := tx.Where("pool = ? AND client_id IS NULL AND released_at IS NOT NULL AND released_at < ?",
+			pool.Name, cutoff).
+			Order("released_at").Order("id").Offset(offset).Limit(reclaimBatchSize).Find(&candidates).Error
+		if err != nil {
+			return nil, fmt.Errorf("bo'shatilgan manzillarni olishda xatolik: %v", err)
+		}
+
+		held, err := heldAddresses(tx, pool, candidates)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range candidates {
+			lease := &candidates[i]
+
+			// Konfiguratsiya o'zgargan bo'lsa, manzil endi reserved bo'lishi mumkin
+			if !pool.IsUsable(pool.AddrAt(lease.HostIndex)) {
+				continue
+			}
+			// Faol client yozuvi manzilni hali ham ishlatib turgan bo'lsa, lease noto'g'ri bo'shatilgan
+			address, addressV6 := pool.AddressesAt(lease.HostIndex)
+			if held[address] {
+				continue
+			}
+			// Soft-delete qilingan eski client yozuvi manzilni unikal index orqali band qilib turadi:
+			// karantin o'tgani uchun u shu tranzaksiyada butunlay o'chiriladi
+			if err := tx.Unscoped().Where("address = ? AND deleted_at IS NOT NULL", address).
+				Delete(&models.WireguardClient{}).Error; err != nil {
+				return nil, fmt.Errorf("eski client yozuvini o'chirishda xatolik: %v", err)
+			}
+
+			lease.Address, lease.AddressV6 = address, addressV6
+			lease.ReleasedAt = nil
+			return lease, nil
+		}
+
+		if len(candidates) < reclaimBatchSize {
+			return nil, nil
+		}
+	}
+}
+
+// heldAddresses - Nomzodlar manzillaridan qaysilari faol clientlarda hali ishlatilayotgani
+func heldAddresses(tx *gorm.DB, pool *ipam.Pool, candidates []models.IPLease) (map[string]bool, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	addresses := make([]string, len(candidates))
+	for i, lease := range candidates {
+		addresses[i], _ = pool.AddressesAt(lease.HostIndex)
+	}
+
+	var existing []string
+	if err := tx.Model(&models.WireguardClient{}).Where("address IN ?", addresses).
+		Pluck("address", &existing).Error; err != nil {
+		return nil, fmt.Errorf("bo'shatilgan manzillarni tekshirishda xatolik: %v", err)
+	}
+
+	held := make(map[string]bool, len(existing))
+	for _, address := range existing {
+		held[address] = true
+	}
+	return held, nil
+}
+
+// QuarantineDuration - Bo'shatilgan manzil qayta berilguncha kutish muddati.
+// ip_quarantine ko'rsatilmagan (yoki manfiy) bo'lsa 24 soat, 0 bo'lsa karantin o'chirilgan.
+func QuarantineDuration() time.Duration {
+	hours := 24
+	if quarantine := config.Config.Wireguard.IPQuarantine; quarantine != nil && *quarantine >= 0 {
+		hours = *quarantine
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// PoolUsage - Pool bandligi statistikasi
+type PoolUsage struct {
+	Pool        string  `json:"pool"`
+	CIDR        string  `json:"cidr"`
+	IPv6CIDR    string  `json:"ipv6_cidr,omitempty"`
+	Size        uint64  `json:"size"`        // Berilishi mumkin bo'lgan manzillar soni
+	Allocated   int64   `json:"allocated"`   // Clientlarga berilgan
+	Quarantined int64   `json:"quarantined"` // Bo'shatilgan, lekin karantin muddati o'tmagan
+	Free        int64   `json:"free"`
+	Utilisation float64 `json:"utilisation"` // Foizda (allocated + quarantined)
+}
+
+// GetPoolUsage - Barcha poollarning bandlik statistikasini olish
+func GetPoolUsage() ([]PoolUsage, error) {
+	cutoff := time.Now().Add(-QuarantineDuration())
+
+	var result []PoolUsage
+	for _, pool := range ipam.Pools() {
+		usage := PoolUsage{
+			Pool: pool.Name,
+			CIDR: pool.Prefix.String(),
+			Size: pool.UsableCount(),
+		}
+		if pool.PrefixV6.IsValid() {
+			usage.IPv6CIDR = pool.PrefixV6.String()
+		}
+
+		if err := DB.Model(&models.IPLease{}).Where("pool = ? AND client_id IS NOT NULL", pool.Name).
+			Count(&usage.Allocated).Error; err != nil {
+			return nil, err
+		}
+		if err := DB.Model(&models.IPLease{}).Where("pool = ? AND client_id IS NULL AND released_at >= ?", pool.Name, cutoff).
+			Count(&usage.Quarantined).Error; err != nil {
+			return nil, err
+		}
+
+		usage.Free = int64(usage.Size) - usage.Allocated - usage.Quarantined
+		if usage.Free < 0 {
+			usage.Free = 0
+		}
+		if usage.Size > 0 {
+			usage.Utilisation = float64(usage.Allocated+usage.Quarantined) * 100 / float64(usage.Size)
+		}
+
+		result = append(result, usage)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Pool < result[j].Pool })
+	return result, nil
+}
+
+// findGap - Band qilinmagan birinchi tartib raqamini topish (faqat pool oxiriga yetilganda ishlatiladi)
+func findGap(tx *gorm.DB, pool *ipam.Pool) (uint32, bool) {
+	var indexes []uint32
+	if err := tx.Model(&models.IPLease{}).Where("pool = ?", pool.Name).
+		Order("host_index").Pluck("host_index", &indexes).Error; err != nil {
+		log.Printf("Bo'sh manzillarni qidirishda xatolik: %v", err)
+		return 0, false
+	}
+
+	candidate, ok := pool.NextUsableIndex(pool.FirstIndex())
+	for _, used := range indexes {
+		if !ok || candidate < used {
+			break
+		}
+		if candidate == used {
+			if candidate == pool.LastIndex() {
+				return 0, false
+			}
+			candidate, ok = pool.NextUsableIndex(candidate + 1)
+		}
+	}
+
+	return candidate, ok
+}
+
+// releaseLease - Client o'chirilganda uning IP manzilini bo'shatish
+func releaseLease(tx *gorm.DB, clientID uint) error {
+	now := time.Now()
+	return tx.Model(&models.IPLease{}).Where("client_id = ?", clientID).
+		Updates(map[string]interface{}{"client_id": nil, "released_at": now}).Error
+}
+
+// backfillLeases - Eski clientlar uchun IP manzil yozuvlarini yaratish (bir martalik migratsiya)
+func backfillLeases() error {
+	var leaseCount int64
+	if err := DB.Model(&models.IPLease{}).Count(&leaseCount).Error; err != nil {
+		return err
+	}
+	if leaseCount > 0 {
+		return nil
+	}
+
+	// O'chirilgan clientlarning manzillari ham band hisoblanadi
+	var clients []models.WireguardClient
+	if err := DB.Unscoped().Find(&clients).Error; err != nil {
+		return err
+	}
+
+	for _, client := range clients {
+		addr, err := netip.ParseAddr(strings.Split(client.Address, "/")[0])
+		if err != nil {
+			log.Printf("Client %d manzili noto'g'ri, o'tkazib yuborildi: %s", client.ID, client.Address)
+			continue
+		}
+
+		for _, pool := range ipam.Pools() {
+			index, ok := pool.IndexOf(addr)
+			if !ok {
+				continue
+			}
+
+			lease := &models.IPLease{
+				Pool:      pool.Name,
+				HostIndex: index,
+				Address:   client.Address,
+				AddressV6: client.AddressV6,
+			}
+			if client.DeletedAt.Valid {
+				releasedAt := client.DeletedAt.Time
+				lease.ReleasedAt = &releasedAt
+			} else {
+				clientID := client.ID
+				lease.ClientID = &clientID
+			}
+
+			if err := DB.Create(lease).Error; err != nil {
+				log.Printf("Client %d uchun IP manzil yozuvini yaratishda xatolik: %v", client.ID, err)
+			}
+			break
+		}
+	}
+
+	log.Printf("%d ta client uchun IP manzil yozuvlari yaratildi", len(clients))
+	return nil
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"wireguard-vpn-client-creater/pkg/config"
+	"wireguard-vpn-client-creater/pkg/ipam"
+	"wireguard-vpn-client-creater/pkg/models"
+)
+
+// setupTestDB - Vaqtinchalik SQLite database va kichik pool bilan test muhitini tayyorlash.
+// Pool 10.9.0.0/29: 10.9.0.1 reserved, clientlarga 10.9.0.2 - 10.9.0.6 beriladi.
+func setupTestDB(t *testing.T, quarantineHours int) {
+	t.Helper()
+
+	previousConfig := config.Config
+	t.Cleanup(func() { config.Config = previousConfig })
+
+	config.Config = config.Configuration{}
+	config.Config.Wireguard.Pools = map[string]config.PoolConfig{
+		string(models.ClientTypeNormal): {CIDR: "10.9.0.0/29", Reserved: []string{"10.9.0.1"}},
+	}
+	config.Config.Wireguard.IPQuarantine = &quarantineHours
+	if err := ipam.InitPools(); err != nil {
+		t.Fatalf("ipam.InitPools xatolik: %v", err)
+	}
+
+	if _, err := InitDB(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("InitDB xatolik: %v", err)
+	}
+	DB = DB.Session(&gorm.Session{Logger: logger.Discard})
+	t.Cleanup(func() {
+		if sqlDB, err := DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+// newTestClient - Databasega saqlanmagan oddiy client
+func newTestClient(name string) *models.WireguardClient {
+	return &models.WireguardClient{
+		PublicKey:   name + "-public",
+		PrivateKey:  name + "-private",
+		Description: name,
+		Type:        models.ClientTypeNormal,
+		Active:      true,
+	}
+}
+
+// mustCreateClient - Clientni pooldan manzil bilan yaratish
+func mustCreateClient(t *testing.T, name string) *models.WireguardClient {
+	t.Helper()
+
+	client := newTestClient(name)
+	if err := CreateClient(client, nil); err != nil {
+		t.Fatalf("CreateClient(%s) xatolik: %v", name, err)
+	}
+	return client
+}
+
+// deleteTestClient - Clientni o'chirib, manzilini berilgan vaqtda bo'shatilgan deb belgilash
+func deleteTestClient(t *testing.T, client *models.WireguardClient, releasedAt time.Time) {
+	t.Helper()
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(client).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.IPLease{}).Where("client_id = ?", client.ID).
+			Updates(map[string]interface{}{"client_id": nil, "released_at": releasedAt}).Error
+	})
+	if err != nil {
+		t.Fatalf("clientni o'chirishda xatolik: %v", err)
+	}
+}
+
+func TestReclaimLeaseQuarantine(t *testing.T) {
+	tests := []struct {
+		name       string
+		quarantine int
+		releasedAt time.Duration // Hozirgi vaqtga nisbatan
+		want       string
+	}{
+		{"karantin o'tgan", 24, -25 * time.Hour, "10.9.0.2/32"},
+		{"karantin o'tmagan", 24, -time.Hour, "10.9.0.4/32"},
+		{"karantin o'chirilgan", 0, -time.Second, "10.9.0.2/32"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t, tt.quarantine)
+
+			first := mustCreateClient(t, "first")
+			mustCreateClient(t, "second")
+			deleteTestClient(t, first, time.Now().Add(tt.releasedAt))
+
+			client := mustCreateClient(t, "third")
+			if client.Address != tt.want {
+				t.Errorf("manzil = %s, kutilgan %s", client.Address, tt.want)
+			}
+		})
+	}
+}
+
+func TestReclaimLeaseFreesSoftDeletedClient(t *testing.T) {
+	setupTestDB(t, 0)
+
+	// Eski versiyada soft-delete qilingan client: backfill uning manzilini bo'shatilgan deb yozadi,
+	// clientlar jadvalidagi yozuv esa manzilni unikal index orqali band qilib turadi
+	legacy := newTestClient("legacy")
+	legacy.Address = "10.9.0.2/32"
+	if err := DB.Create(legacy).Error; err != nil {
+		t.Fatalf("eski clientni saqlashda xatolik: %v", err)
+	}
+	if err := DB.Delete(legacy).Error; err != nil {
+		t.Fatalf("eski clientni soft-delete qilishda xatolik: %v", err)
+	}
+	releasedAt := time.Now().Add(-time.Hour)
+	if err := DB.Create(&models.IPLease{Pool: "normal", HostIndex: 2, Address: legacy.Address, ReleasedAt: &releasedAt}).Error; err != nil {
+		t.Fatalf("lease yozuvini saqlashda xatolik: %v", err)
+	}
+
+	client := mustCreateClient(t, "reused")
+	if client.Address != legacy.Address {
+		t.Errorf("manzil = %s, kutilgan %s", client.Address, legacy.Address)
+	}
+
+	var count int64
+	if err := DB.Unscoped().Model(&models.WireguardClient{}).Where("id = ?", legacy.ID).Count(&count).Error; err != nil {
+		t.Fatalf("eski clientni tekshirishda xatolik: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("soft-delete qilingan eski client yozuvi o'chirilmagan")
+	}
+}
+
+func TestCreateClientAllocatesSequentially(t *testing.T) {
+	setupTestDB(t, 24)
+
+	for _, want := range []string{"10.9.0.2/32", "10.9.0.3/32", "10.9.0.4/32"} {
+		client := mustCreateClient(t, want)
+		if client.Address != want {
+			t.Errorf("manzil = %s, kutilgan %s", client.Address, want)
+		}
+
+		var lease models.IPLease
+		if err := DB.Where("client_id = ?", client.ID).First(&lease).Error; err != nil {
+			t.Fatalf("client %d uchun lease topilmadi: %v", client.ID, err)
+		}
+		if lease.Address != want || lease.Pool != "normal" {
+			t.Errorf("lease = %s (%s), kutilgan %s (normal)", lease.Address, lease.Pool, want)
+		}
+	}
+}
+
+func TestCreateClientFillsGaps(t *testing.T) {
+	setupTestDB(t, 24)
+
+	first := mustCreateClient(t, "first")
+	middle := mustCreateClient(t, "middle")
+	mustCreateClient(t, "last")
+
+	// Lease yozuvi yo'q manzil (masalan pool konfiguratsiyasi o'zgargandan keyin) oraliq sifatida qoladi
+	if err := DB.Unscoped().Delete(middle).Error; err != nil {
+		t.Fatalf("clientni o'chirishda xatolik: %v", err)
+	}
+	if err := DB.Where("client_id = ?", middle.ID).Delete(&models.IPLease{}).Error; err != nil {
+		t.Fatalf("lease ni o'chirishda xatolik: %v", err)
+	}
+	// Karantindagi manzil oraliq hisoblanmaydi
+	deleteTestClient(t, first, time.Now())
+
+	// Avval pool oxirigacha, so'ng oraliq to'ldiriladi
+	for _, want := range []string{"10.9.0.5/32", "10.9.0.6/32", "10.9.0.3/32"} {
+		client := mustCreateClient(t, "client-"+want)
+		if client.Address != want {
+			t.Errorf("manzil = %s, kutilgan %s", client.Address, want)
+		}
+	}
+
+	err := CreateClient(newTestClient("overflow"), nil)
+	if !errors.Is(err, ipam.ErrPoolExhausted) {
+		t.Errorf("CreateClient xatolik = %v, kutilgan ipam.ErrPoolExhausted", err)
+	}
+}
+
+func TestCreateClientConcurrent(t *testing.T) {
+	setupTestDB(t, 24)
+
+	const workers = 5
+	clients := make([]*models.WireguardClient, workers)
+	errs := make([]error, workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			clients[i] = newTestClient(fmt.Sprintf("client-%d", i))
+			errs[i] = CreateClient(clients[i], nil)
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i, client := range clients {
+		if errs[i] != nil {
+			t.Fatalf("CreateClient(%d) xatolik: %v", i, errs[i])
+		}
+		if seen[client.Address] {
+			t.Errorf("manzil %s ikki marta berildi", client.Address)
+		}
+		seen[client.Address] = true
+	}
+
+	err := CreateClient(newTestClient("overflow"), nil)
+	if !errors.Is(err, ipam.ErrPoolExhausted) {
+		t.Errorf("CreateClient xatolik = %v, kutilgan ipam.ErrPoolExhausted", err)
+	}
+}
@@ -0,0 +1,247 @@
+package database
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wireguard-vpn-client-creater/pkg/events"
+	"wireguard-vpn-client-creater/pkg/models"
+	"wireguard-vpn-client-creater/pkg/wireguard"
+)
+
+// Rollback yozuvlari uchun amal turlari
+const (
+	OperationCreate = "create"
+	OperationDelete = "delete"
+	OperationUpdate = "update"
+)
+
+// peerMu - Database va interfaceni birga o'zgartiruvchi amallar hamda drift tekshiruvi orasidagi qulf.
+// Amallar bir-biri bilan parallel bajarilishi mumkin (RLock), drift tekshiruvi esa ular tugashini kutadi (Lock).
+// Aks holda tekshiruv commit bo'lmagan clientning peerini orphan deb o'chirib yuborishi mumkin.
+var peerMu sync.RWMutex
+
+// LockPeers - Boshlangan lifecycle amallari tugashini kutish va yangilarini to'xtatib turish (drift tekshiruvi uchun)
+func LockPeers() {
+	peerMu.Lock()
+}
+
+// UnlockPeers - LockPeers qulfini ochish
+func UnlockPeers() {
+	peerMu.Unlock()
+}
+
+// ProvisionClient - Clientni databasega saqlash va peerni interfacega qo'shish.
+// Peer qo'shilmasa database o'zgarishi bekor qilinadi va interfacega tushib qolgan peer olib tashlanadi.
+func ProvisionClient(client *models.WireguardClient, backend wireguard.Backend) error {
+	peerMu.RLock()
+	defer peerMu.RUnlock()
+
+	// Backend xatolik qaytarsa ham peer interfacega qo'shilgan bo'lishi mumkin (masalan wg-quick save xatoligi),
+	// shuning uchun AddPeer chaqirilgan bo'lsa peer har doim tekshirilib olib tashlanadi
+	addAttempted := false
+	err := CreateClient(client, func(client *models.WireguardClient) error {
+		addAttempted = true
+		return backend.AddPeer(wireguard.PeerFromClient(client))
+	})
+
+	if err != nil {
+		if addAttempted {
+			removed := *client
+			removed.Active = false
+			if rollbackErr := applyPeerState(backend, &removed); rollbackErr != nil {
+				recordRollbackFailure(OperationCreate, client, err, rollbackErr)
+			}
+		}
+		return err
+	}
+
+	events.PublishClient(events.ClientCreated, client)
+	return nil
+}
+
+// DeprovisionClient - Peerni interfacedan olib tashlash va clientni databasedan to'liq o'chirish.
+// Peer olib tashlanmasa database o'zgarmaydi, database o'zgarishi commit bo'lmasa peer qayta qo'shiladi.
+// eventType - o'chirish sababiga mos yagona hodisa (events.ClientDeleted yoki events.ClientExpired).
+func DeprovisionClient(client *models.WireguardClient, eventType string, backend wireguard.Backend) error {
+	err := runWithPeerChange(OperationDelete, client,
+		func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Delete(client).Error; err != nil {
+				return err
+			}
+			return releaseLease(tx, client.ID)
+		},
+		func() error {
+			// Faol bo'lmagan clientning peeri interfaceda bo'lmaydi
+			if !client.Active {
+				return nil
+			}
+			return backend.RemovePeer(client.PublicKey)
+		},
+		func() error {
+			if !client.Active {
+				return nil
+			}
+			return backend.AddPeer(wireguard.PeerFromClient(client))
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	events.PublishClient(eventType, client)
+	return nil
+}
+
+// SaveClientWithPeer - Client o'zgarishlarini saqlash va peer holatini unga moslashtirish.
+// previous - o'zgarishdan oldingi client holati (xatolik bo'lsa interface shu holatga qaytariladi),
+// columns - databasega yoziladigan ustunlar (qolganlari, masalan traffic hisoblagichlari, o'zgarmaydi).
+func SaveClientWithPeer(client *models.WireguardClient, previous models.WireguardClient, backend wireguard.Backend, columns ...string) error {
+	return runWithPeerChange(OperationUpdate, client,
+		func(tx *gorm.DB) error {
+			return updateClientColumns(tx, client, columns)
+		},
+		func() error {
+			return applyPeerState(backend, client)
+		},
+		func() error {
+			return applyPeerState(backend, &previous)
+		},
+	)
+}
+
+// suspendColumns - To'xtatish va qayta yoqishda o'zgaradigan ustunlar.
+// Peer olib tashlanib qayta qo'shilganda interface hisoblagichlari nolga tushadi, shuning uchun
+// oxirgi o'qilgan qiymatlar ham nolga tushiriladi.
+var suspendColumns = []string{"active", "suspended_at", "suspend_reason", "last_rx_bytes", "last_tx_bytes"}
+
+// SuspendClient - Clientni to'xtatib qo'yish: peer interfacedan olib tashlanadi,
+// kalitlar, manzil va tarix databaseda saqlanib qoladi.
+// Hisoblagichlar nolga tushirilishidan oldin oxirgi o'qishdan beri ishlatilgan traffic hisobga olinadi.
+func SuspendClient(client *models.WireguardClient, reason string, backend wireguard.Backend) error {
+	previous := *client
+
+	now := time.Now()
+	client.Active = false
+	client.SuspendedAt = &now
+	client.SuspendReason = reason
+	client.LastRxBytes, client.LastTxBytes = 0, 0
+
+	err := runWithPeerChange(OperationUpdate, client,
+		func(tx *gorm.DB) error {
+			if err := accountFinalTraffic(tx, client, backend); err != nil {
+				return err
+			}
+			return updateClientColumns(tx, client, suspendColumns)
+		},
+		func() error {
+			return applyPeerState(backend, client)
+		},
+		func() error {
+			return applyPeerState(backend, &previous)
+		},
+	)
+	if err != nil {
+		*client = previous
+		return err
+	}
+
+	events.PublishClient(events.ClientSuspended, client)
+	return nil
+}
+
+// ResumeClient - To'xtatilgan clientni qayta yoqish: peer avvalgi konfiguratsiya bilan tiklanadi
+func ResumeClient(client *models.WireguardClient, backend wireguard.Backend) error {
+	previous := *client
+
+	client.Active = true
+	client.SuspendedAt = nil
+	client.SuspendReason = ""
+	client.LastRxBytes, client.LastTxBytes = 0, 0
+
+	if err := SaveClientWithPeer(client, previous, backend, suspendColumns...); err != nil {
+		*client = previous
+		return err
+	}
+
+	events.PublishClient(events.ClientResumed, client)
+	return nil
+}
+
+// applyPeerState - Faol client peerini qo'shish, faol bo'lmaganini olib tashlash
+func applyPeerState(backend wireguard.Backend, client *models.WireguardClient) error {
+	if client.Active {
+		return backend.AddPeer(wireguard.PeerFromClient(client))
+	}
+
+	// Peer allaqachon olib tashlangan bo'lishi mumkin
+	peers, err := backend.ListPeers()
+	if err != nil {
+		return err
+	}
+	for _, peer := range peers {
+		if peer.PublicKey == client.PublicKey {
+			return backend.RemovePeer(client.PublicKey)
+		}
+	}
+	return nil
+}
+
+// runWithPeerChange - Database va Wireguard o'zgarishini bitta amal sifatida bajarish.
+// Avval database o'zgarishi tranzaksiya ichida bajariladi, so'ng peer o'zgartiriladi va commit qilinadi.
+// apply yoki commit muvaffaqiyatsiz bo'lsa peer o'zgarishi undo orqali qaytariladi: apply xatolik qaytarganda ham
+// interface o'zgargan bo'lishi mumkin, shuning uchun undo idempotent bo'lishi kerak.
+func runWithPeerChange(operation string, client *models.WireguardClient, dbChange func(tx *gorm.DB) error, apply func() error, undo func() error) error {
+	peerMu.RLock()
+	defer peerMu.RUnlock()
+
+	applyAttempted := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := dbChange(tx); err != nil {
+			return err
+		}
+		applyAttempted = true
+		return apply()
+	})
+
+	if err != nil && applyAttempted {
+		if rollbackErr := undo(); rollbackErr != nil {
+			recordRollbackFailure(operation, client, err, rollbackErr)
+		}
+	}
+
+	return err
+}
+
+// recordRollbackFailure - Qaytarib bo'lmagan o'zgarishni keyinchalik tuzatish uchun saqlash
+func recordRollbackFailure(operation string, client *models.WireguardClient, cause, rollbackErr error) {
+	log.Printf("Xatolik: client %d (%s) uchun %s amalini qaytarib bo'lmadi: %v (asosiy xatolik: %v)",
+		client.ID, client.PublicKey, operation, rollbackErr, cause)
+
+	failure := &models.RollbackFailure{
+		Operation:   operation,
+		ClientID:    client.ID,
+		PublicKey:   client.PublicKey,
+		Error:       cause.Error(),
+		RollbackErr: rollbackErr.Error(),
+	}
+	if err := DB.Create(failure).Error; err != nil {
+		log.Printf("Rollback xatoligini saqlashda xatolik: %v", err)
+	}
+}
+
+// GetUnresolvedRollbackFailures - Hali tuzatilmagan rollback xatoliklarini olish
+func GetUnresolvedRollbackFailures() ([]models.RollbackFailure, error) {
+	var failures []models.RollbackFailure
+	err := DB.Where("resolved_at IS NULL").Order("id").Find(&failures).Error
+	return failures, err
+}
+
+// ResolveRollbackFailures - Barcha ochiq rollback xatoliklarini tuzatilgan deb belgilash
+func ResolveRollbackFailures() error {
+	return DB.Model(&models.RollbackFailure{}).Where("resolved_at IS NULL").
+		Update("resolved_at", time.Now()).Error
+}
@@ -0,0 +1,330 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"wireguard-vpn-client-creater/pkg/events"
+	"wireguard-vpn-client-creater/pkg/models"
+	"wireguard-vpn-client-creater/pkg/wireguard"
+)
+
+// errBackend - Backend xatoligini simulyatsiya qilish uchun
+var errBackend = errors.New("backend xatoligi")
+
+// faultyBackend - Berilgan amallarda xatolik qaytaradigan MemoryBackend.
+// applyBeforeFail bo'lsa o'zgarish avval bajariladi (ExecBackend dagi wg-quick save xatoligi kabi).
+type faultyBackend struct {
+	*wireguard.MemoryBackend
+	failAdd         bool
+	failRemove      bool
+	applyBeforeFail bool
+}
+
+func (b *faultyBackend) AddPeer(peer wireguard.Peer) error {
+	if !b.failAdd {
+		return b.MemoryBackend.AddPeer(peer)
+	}
+	if b.applyBeforeFail {
+		b.MemoryBackend.AddPeer(peer)
+	}
+	return errBackend
+}
+
+func (b *faultyBackend) RemovePeer(publicKey string) error {
+	if !b.failRemove {
+		return b.MemoryBackend.RemovePeer(publicKey)
+	}
+	if b.applyBeforeFail {
+		b.MemoryBackend.RemovePeer(publicKey)
+	}
+	return errBackend
+}
+
+// newPeerClient - Haqiqiy kalitlar bilan client (MemoryBackend kalitlarni tekshiradi)
+func newPeerClient(t *testing.T, name string) *models.WireguardClient {
+	t.Helper()
+
+	privateKey, publicKey, err := wireguard.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair xatolik: %v", err)
+	}
+
+	client := newTestClient(name)
+	client.PrivateKey = privateKey
+	client.PublicKey = publicKey
+	return client
+}
+
+// hasPeer - Backendda peer mavjudligini tekshirish
+func hasPeer(t *testing.T, backend wireguard.Backend, publicKey string) bool {
+	t.Helper()
+
+	peers, err := backend.ListPeers()
+	if err != nil {
+		t.Fatalf("ListPeers xatolik: %v", err)
+	}
+	for _, peer := range peers {
+		if peer.PublicKey == publicKey {
+			return true
+		}
+	}
+	return false
+}
+
+// clientExists - Client databaseda (soft-delete qilinganlari ham) mavjudligini tekshirish
+func clientExists(t *testing.T, id uint) bool {
+	t.Helper()
+
+	var count int64
+	if err := DB.Unscoped().Model(&models.WireguardClient{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		t.Fatalf("clientni tekshirishda xatolik: %v", err)
+	}
+	return count > 0
+}
+
+// assertNoRollbackFailures - Qaytarib bo'lmagan o'zgarishlar yozilmaganini tekshirish
+func assertNoRollbackFailures(t *testing.T) {
+	t.Helper()
+
+	failures, err := GetUnresolvedRollbackFailures()
+	if err != nil {
+		t.Fatalf("GetUnresolvedRollbackFailures xatolik: %v", err)
+	}
+	if len(failures) != 0 {
+		t.Errorf("rollback xatoliklari = %+v, kutilgan bo'sh", failures)
+	}
+}
+
+func TestProvisionClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		backend  *faultyBackend
+		wantErr  bool
+		wantPeer bool
+	}{
+		{"muvaffaqiyatli", &faultyBackend{}, false, true},
+		{"peer qo'shilmadi", &faultyBackend{failAdd: true}, true, false},
+		{"peer qo'shildi, lekin xatolik qaytdi", &faultyBackend{failAdd: true, applyBeforeFail: true}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t, 24)
+			tt.backend.MemoryBackend = wireguard.NewMemoryBackend("wg-test")
+
+			client := newPeerClient(t, "client")
+			err := ProvisionClient(client, tt.backend)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProvisionClient xatolik = %v, kutilgan xatolik: %v", err, tt.wantErr)
+			}
+
+			if got := hasPeer(t, tt.backend, client.PublicKey); got != tt.wantPeer {
+				t.Errorf("peer interfaceda = %v, kutilgan %v", got, tt.wantPeer)
+			}
+
+			var leases int64
+			DB.Model(&models.IPLease{}).Where("client_id IS NOT NULL").Count(&leases)
+			wantLeases := int64(1)
+			if tt.wantErr {
+				wantLeases = 0
+			}
+			if leases != wantLeases {
+				t.Errorf("band qilingan manzillar = %d, kutilgan %d", leases, wantLeases)
+			}
+			if tt.wantErr && client.ID != 0 && clientExists(t, client.ID) {
+				t.Error("xatolikdan keyin client databaseda qolmasligi kerak")
+			}
+			assertNoRollbackFailures(t)
+		})
+	}
+}
+
+func TestDeprovisionClientRollback(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend *faultyBackend
+	}{
+		{"peer olib tashlanmadi", &faultyBackend{failRemove: true}},
+		{"peer olib tashlandi, lekin xatolik qaytdi", &faultyBackend{failRemove: true, applyBeforeFail: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t, 24)
+			tt.backend.MemoryBackend = wireguard.NewMemoryBackend("wg-test")
+
+			client := newPeerClient(t, "client")
+			if err := ProvisionClient(client, tt.backend); err != nil {
+				t.Fatalf("ProvisionClient xatolik: %v", err)
+			}
+
+			if err := DeprovisionClient(client, events.ClientDeleted, tt.backend); err == nil {
+				t.Fatal("DeprovisionClient xatolik qaytarishi kerak")
+			}
+
+			if !clientExists(t, client.ID) {
+				t.Error("client databaseda qolishi kerak")
+			}
+			if !hasPeer(t, tt.backend, client.PublicKey) {
+				t.Error("peer interfaceda qolishi (yoki qayta qo'shilishi) kerak")
+			}
+			assertNoRollbackFailures(t)
+		})
+	}
+}
+
+func TestSuspendClientRollback(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend *faultyBackend
+	}{
+		{"peer olib tashlanmadi", &faultyBackend{failRemove: true}},
+		{"peer olib tashlandi, lekin xatolik qaytdi", &faultyBackend{failRemove: true, applyBeforeFail: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t, 24)
+			tt.backend.MemoryBackend = wireguard.NewMemoryBackend("wg-test")
+
+			client := newPeerClient(t, "client")
+			if err := ProvisionClient(client, tt.backend); err != nil {
+				t.Fatalf("ProvisionClient xatolik: %v", err)
+			}
+
+			if err := SuspendClient(client, "test", tt.backend); err == nil {
+				t.Fatal("SuspendClient xatolik qaytarishi kerak")
+			}
+			if !client.Active || client.SuspendedAt != nil {
+				t.Error("xatolikdan keyin client obyekti avvalgi holatiga qaytishi kerak")
+			}
+
+			var stored models.WireguardClient
+			if err := DB.First(&stored, client.ID).Error; err != nil {
+				t.Fatalf("clientni o'qishda xatolik: %v", err)
+			}
+			if !stored.Active || stored.SuspendedAt != nil {
+				t.Error("databasedagi client faol qolishi kerak")
+			}
+			if !hasPeer(t, tt.backend, client.PublicKey) {
+				t.Error("peer interfaceda qolishi (yoki qayta qo'shilishi) kerak")
+			}
+			assertNoRollbackFailures(t)
+		})
+	}
+}
+
+func TestRollbackFailureRecorded(t *testing.T) {
+	setupTestDB(t, 24)
+	backend := &faultyBackend{MemoryBackend: wireguard.NewMemoryBackend("wg-test")}
+
+	client := newPeerClient(t, "client")
+	if err := ProvisionClient(client, backend); err != nil {
+		t.Fatalf("ProvisionClient xatolik: %v", err)
+	}
+
+	// Peer olib tashlandi, xatolik qaytdi, undo (qayta qo'shish) ham muvaffaqiyatsiz
+	backend.failRemove, backend.failAdd, backend.applyBeforeFail = true, true, true
+	if err := SuspendClient(client, "test", backend); err == nil {
+		t.Fatal("SuspendClient xatolik qaytarishi kerak")
+	}
+
+	failures, err := GetUnresolvedRollbackFailures()
+	if err != nil {
+		t.Fatalf("GetUnresolvedRollbackFailures xatolik: %v", err)
+	}
+	if len(failures) != 1 || failures[0].Operation != OperationUpdate || failures[0].ClientID != client.ID {
+		t.Errorf("rollback xatoliklari = %+v, kutilgan bitta %s yozuvi", failures, OperationUpdate)
+	}
+}
+
+func TestSuspendResumeKeepsTrafficCounters(t *testing.T) {
+	setupTestDB(t, 24)
+	backend := wireguard.NewMemoryBackend("wg-test")
+
+	client := newPeerClient(t, "client")
+	if err := ProvisionClient(client, backend); err != nil {
+		t.Fatalf("ProvisionClient xatolik: %v", err)
+	}
+
+	// Traffic monitor client o'qilgandan keyin hisobni yangilaydi
+	err := DB.Model(&models.WireguardClient{}).Where("id = ?", client.ID).Updates(map[string]interface{}{
+		"used_bytes":    int64(5000),
+		"last_rx_bytes": int64(3000),
+		"last_tx_bytes": int64(2000),
+	}).Error
+	if err != nil {
+		t.Fatalf("traffic hisobini yozishda xatolik: %v", err)
+	}
+
+	for _, step := range []struct {
+		name string
+		run  func() error
+	}{
+		{"SuspendClient", func() error { return SuspendClient(client, "test", backend) }},
+		{"ResumeClient", func() error { return ResumeClient(client, backend) }},
+	} {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s xatolik: %v", step.name, err)
+		}
+
+		var stored models.WireguardClient
+		if err := DB.First(&stored, client.ID).Error; err != nil {
+			t.Fatalf("clientni o'qishda xatolik: %v", err)
+		}
+		if stored.UsedBytes != 5000 {
+			t.Errorf("%s: used_bytes = %d, kutilgan 5000", step.name, stored.UsedBytes)
+		}
+		if stored.LastRxBytes != 0 || stored.LastTxBytes != 0 {
+			t.Errorf("%s: last_rx/tx_bytes = %d/%d, kutilgan 0/0", step.name, stored.LastRxBytes, stored.LastTxBytes)
+		}
+		if stored.Active != client.Active {
+			t.Errorf("%s: active = %v, kutilgan %v", step.name, stored.Active, client.Active)
+		}
+	}
+}
+
+func TestSuspendClientAccountsFinalTraffic(t *testing.T) {
+	setupTestDB(t, 24)
+	backend := wireguard.NewMemoryBackend("wg-test")
+
+	client := newPeerClient(t, "client")
+	if err := ProvisionClient(client, backend); err != nil {
+		t.Fatalf("ProvisionClient xatolik: %v", err)
+	}
+
+	// Oxirgi o'qishdan keyin peer yana 1000/500 bayt ishlatgan
+	err := DB.Model(&models.WireguardClient{}).Where("id = ?", client.ID).Updates(map[string]interface{}{
+		"used_bytes":    int64(5000),
+		"last_rx_bytes": int64(3000),
+		"last_tx_bytes": int64(2000),
+	}).Error
+	if err != nil {
+		t.Fatalf("traffic hisobini yozishda xatolik: %v", err)
+	}
+	backend.SetPeerStats(wireguard.PeerStats{PublicKey: client.PublicKey, BytesReceived: 4000, BytesSent: 2500})
+
+	if err := SuspendClient(client, "test", backend); err != nil {
+		t.Fatalf("SuspendClient xatolik: %v", err)
+	}
+
+	var stored models.WireguardClient
+	if err := DB.First(&stored, client.ID).Error; err != nil {
+		t.Fatalf("clientni o'qishda xatolik: %v", err)
+	}
+	if stored.UsedBytes != 6500 || client.UsedBytes != 6500 {
+		t.Errorf("used_bytes = %d (obyektda %d), kutilgan 6500", stored.UsedBytes, client.UsedBytes)
+	}
+	if stored.LastRxBytes != 0 || stored.LastTxBytes != 0 {
+		t.Errorf("last_rx/tx_bytes = %d/%d, kutilgan 0/0", stored.LastRxBytes, stored.LastTxBytes)
+	}
+
+	var samples []models.TrafficSample
+	if err := DB.Where("client_id = ?", client.ID).Find(&samples).Error; err != nil {
+		t.Fatalf("traffic tarixini o'qishda xatolik: %v", err)
+	}
+	if len(samples) != 1 || samples[0].BytesReceived != 1000 || samples[0].BytesSent != 500 {
+		t.Errorf("traffic tarixi = %+v, kutilgan bitta 1000/500 yozuvi", samples)
+	}
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"time"
+
+	"wireguard-vpn-client-creater/pkg/events"
+	"wireguard-vpn-client-creater/pkg/models"
+	"wireguard-vpn-client-creater/pkg/wireguard"
+)
+
+// SessionTimeout - Oxirgi handshakedan keyin client oflayn hisoblanadigan vaqt.
+// Wireguard faol ulanishda har 2 minutda qayta handshake qiladi.
+const SessionTimeout = 3 * time.Minute
+
+// TrackSessions - Peerlarning oxirgi handshake vaqtidan LastConnected va sessiyalarni yangilash.
+// deltas - shu davrda AccountTraffic hisoblagan traffic farqlari.
+func TrackSessions(peers []wireguard.PeerStats, deltas []TrafficDelta, now time.Time) error {
+	peerMap := make(map[string]wireguard.PeerStats, len(peers))
+	for _, peer := range peers {
+		peerMap[peer.PublicKey] = peer
+	}
+
+	deltaMap := make(map[uint]TrafficDelta, len(deltas))
+	for _, delta := range deltas {
+		deltaMap[delta.ClientID] = delta
+	}
+
+	var clients []models.WireguardClient
+	if err := DB.Find(&clients).Error; err != nil {
+		return err
+	}
+
+	var openSessions []models.ClientSession
+	if err := DB.Where("ended_at IS NULL").Find(&openSessions).Error; err != nil {
+		return err
+	}
+	openMap := make(map[uint]*models.ClientSession, len(openSessions))
+	for i := range openSessions {
+		openMap[openSessions[i].ClientID] = &openSessions[i]
+	}
+
+	for _, client := range clients {
+		session := openMap[client.ID]
+		delete(openMap, client.ID)
+		peer, exists := peerMap[client.PublicKey]
+
+		// Peer interfaceda yo'q (to'xtatilgan) yoki hali ulanmagan
+		if !exists || peer.LatestHandshake.IsZero() {
+			if session != nil {
+				if err := closeSession(session); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		handshake := peer.LatestHandshake
+		if handshake.After(client.LastConnected) {
+			err := DB.Model(&models.WireguardClient{}).Where("id = ?", client.ID).
+				Update("last_connected", handshake).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// Uzilishdan keyin yangi handshake bo'lsa, eski sessiya yopiladi
+		if session != nil && handshake.Sub(session.LastHandshakeAt) > SessionTimeout {
+			if err := closeSession(session); err != nil {
+				return err
+			}
+			session = nil
+		}
+
+		online := now.Sub(handshake) <= SessionTimeout
+		started := false
+		if session == nil {
+			if !online {
+				continue
+			}
+			started = true
+			session = &models.ClientSession{
+				ClientID:  client.ID,
+				StartedAt: handshake,
+			}
+		}
+
+		delta := deltaMap[client.ID]
+		session.LastHandshakeAt = handshake
+		session.BytesReceived += delta.BytesReceived
+		session.BytesSent += delta.BytesSent
+		if peer.Endpoint != "" {
+			session.Endpoint = peer.Endpoint
+		}
+		if err := DB.Save(session).Error; err != nil {
+			return err
+		}
+		if started {
+			events.Publish(events.ClientOnline, client.ID, session)
+		}
+
+		// Handshake eskirgan bo'lsa, sessiya tugagan hisoblanadi
+		if !online {
+			if err := closeSession(session); err != nil {
+				return err
+			}
+		}
+	}
+
+	// O'chirilgan clientlarning ochiq sessiyalarini yopish
+	for _, session := range openMap {
+		if err := closeSession(session); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// closeSession - Sessiyani oxirgi handshake vaqti bilan yopish
+func closeSession(session *models.ClientSession) error {
+	endedAt := session.LastHandshakeAt
+	session.EndedAt = &endedAt
+	if err := DB.Model(session).Update("ended_at", endedAt).Error; err != nil {
+		return err
+	}
+
+	events.Publish(events.ClientOffline, session.ClientID, session)
+	return nil
+}
+
+// GetClientSessions - Client sessiyalarini eng yangisidan boshlab olish
+func GetClientSessions(clientID uint, limit, offset int) ([]models.ClientSession, int64, error) {
+	var total int64
+	if err := DB.Model(&models.ClientSession{}).Where("client_id = ?", clientID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var sessions []models.ClientSession
+	err := DB.Where("client_id = ?", clientID).Order("started_at DESC").Limit(limit).Offset(offset).Find(&sessions).Error
+	return sessions, total, err
+}
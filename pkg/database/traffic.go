@@ -0,0 +1,165 @@
+package database
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wireguard-vpn-client-creater/pkg/models"
+	"wireguard-vpn-client-creater/pkg/wireguard"
+)
+
+// TrafficDelta - Ikki o'qish orasida client trafficidagi o'zgarish
+type TrafficDelta struct {
+	ClientID      uint  `json:"client_id"`
+	BytesReceived int64 `json:"bytes_received"`
+	BytesSent     int64 `json:"bytes_sent"`
+}
+
+// AccountTraffic - Interface hisoblagichlaridan clientlar ishlatgan trafficni hisoblash
+// va farqni traffic tarixiga yozish.
+// Interface qayta ishga tushganda hisoblagichlar nolga tushadi: bunday holatda
+// joriy qiymat to'liq yangi traffic sifatida qabul qilinadi.
+// Barcha clientlar bitta tranzaksiyada yoziladi; bitta client xatoligi faqat uning savepointini
+// bekor qiladi va logga yoziladi, qolgan clientlar hisobi davom etadi.
+func AccountTraffic(peers []wireguard.PeerStats) ([]TrafficDelta, error) {
+	sampledAt := time.Now().UTC().Truncate(time.Second)
+
+	var clients []models.WireguardClient
+	if err := DB.Where("active = ?", true).Find(&clients).Error; err != nil {
+		return nil, err
+	}
+
+	peerMap := make(map[string]wireguard.PeerStats, len(peers))
+	for _, peer := range peers {
+		peerMap[peer.PublicKey] = peer
+	}
+
+	var deltas []TrafficDelta
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for i := range clients {
+			peer, exists := peerMap[clients[i].PublicKey]
+			if !exists {
+				continue
+			}
+
+			// Hisob va tarix bitta savepointda yoziladi, farq ikki marta hisoblanmasligi uchun
+			var delta TrafficDelta
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				var err error
+				delta, err = accountClientTraffic(tx, &clients[i], peer, sampledAt)
+				return err
+			})
+			if err != nil {
+				log.Printf("Client %d trafficini hisoblashda xatolik: %v", clients[i].ID, err)
+				continue
+			}
+
+			if delta.BytesReceived > 0 || delta.BytesSent > 0 {
+				deltas = append(deltas, delta)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deltas, nil
+}
+
+// accountClientTraffic - Bitta client uchun oxirgi o'qilgan hisoblagichlardan farqni hisoblash,
+// used_bytes va oxirgi qiymatlarni yangilash hamda farqni traffic tarixiga yozish
+func accountClientTraffic(tx *gorm.DB, client *models.WireguardClient, peer wireguard.PeerStats, sampledAt time.Time) (TrafficDelta, error) {
+	delta := TrafficDelta{
+		ClientID:      client.ID,
+		BytesReceived: counterDelta(client.LastRxBytes, peer.BytesReceived),
+		BytesSent:     counterDelta(client.LastTxBytes, peer.BytesSent),
+	}
+
+	// Hisoblagich o'zgarmagan bo'lsa yozish shart emas
+	if delta.BytesReceived == 0 && delta.BytesSent == 0 &&
+		client.LastRxBytes == peer.BytesReceived && client.LastTxBytes == peer.BytesSent {
+		return delta, nil
+	}
+
+	err := tx.Model(&models.WireguardClient{}).Where("id = ?", client.ID).Updates(map[string]interface{}{
+		"used_bytes":    gorm.Expr("used_bytes + ?", delta.BytesReceived+delta.BytesSent),
+		"last_rx_bytes": peer.BytesReceived,
+		"last_tx_bytes": peer.BytesSent,
+	}).Error
+	if err != nil {
+		return delta, err
+	}
+
+	if delta.BytesReceived == 0 && delta.BytesSent == 0 {
+		return delta, nil
+	}
+	return delta, tx.Create(&models.TrafficSample{
+		ClientID:      client.ID,
+		SampledAt:     sampledAt,
+		BytesReceived: delta.BytesReceived,
+		BytesSent:     delta.BytesSent,
+	}).Error
+}
+
+// accountFinalTraffic - Peer olib tashlanishidan oldin oxirgi o'qishdan beri ishlatilgan trafficni hisobga olish.
+// Hisoblagichlar databasedan tranzaksiya ichida qayta o'qiladi, client.UsedBytes yangi qiymatga o'rnatiladi.
+func accountFinalTraffic(tx *gorm.DB, client *models.WireguardClient, backend wireguard.Backend) error {
+	peers, err := backend.ListPeers()
+	if err != nil {
+		return err
+	}
+
+	for _, peer := range peers {
+		if peer.PublicKey != client.PublicKey {
+			continue
+		}
+
+		var current models.WireguardClient
+		if err := tx.Select("id", "used_bytes", "last_rx_bytes", "last_tx_bytes").First(&current, client.ID).Error; err != nil {
+			return err
+		}
+
+		delta, err := accountClientTraffic(tx, &current, peer, time.Now().UTC().Truncate(time.Second))
+		if err != nil {
+			return err
+		}
+		client.UsedBytes = current.UsedBytes + delta.BytesReceived + delta.BytesSent
+		return nil
+	}
+	return nil
+}
+
+// counterDelta - Hisoblagichning oldingi qiymatidan farqini hisoblash
+func counterDelta(previous, current int64) int64 {
+	if current < previous {
+		// Hisoblagich qayta boshlangan (interface yoki peer qayta qo'shilgan)
+		return current
+	}
+	return current - previous
+}
+
+// GetClientsOverQuota - Kvotasi tugagan faol clientlarni olish
+func GetClientsOverQuota() ([]models.WireguardClient, error) {
+	var clients []models.WireguardClient
+	err := DB.Where("active = ? AND quota_bytes > 0 AND used_bytes >= quota_bytes", true).Find(&clients).Error
+	return clients, err
+}
+
+// GetClientsDueForReset - Joriy davri periodStart dan oldin boshlangan oylik kvotali clientlarni olish
+func GetClientsDueForReset(periodStart time.Time) ([]models.WireguardClient, error) {
+	var clients []models.WireguardClient
+	err := DB.Where("quota_period = ? AND (period_started_at IS NULL OR period_started_at < ?)",
+		models.QuotaPeriodMonthly, periodStart).Find(&clients).Error
+	return clients, err
+}
+
+// ResetClientUsage - Client kvota davrini yangidan boshlash
+func ResetClientUsage(clientID uint, periodStart time.Time) error {
+	return DB.Model(&models.WireguardClient{}).Where("id = ?", clientID).Updates(map[string]interface{}{
+		"used_bytes":        0,
+		"period_started_at": periodStart,
+	}).Error
+}
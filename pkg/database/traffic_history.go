@@ -0,0 +1,70 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"wireguard-vpn-client-creater/pkg/models"
+)
+
+// Traffic tarixini guruhlash oraliqlari
+const (
+	BucketHour  = "hour"
+	BucketDay   = "day"
+	BucketMonth = "month"
+)
+
+// bucketFormats - Har bir oraliq uchun SQLite strftime formati (UTC)
+var bucketFormats = map[string]string{
+	BucketHour:  "%Y-%m-%dT%H:00:00Z",
+	BucketDay:   "%Y-%m-%dT00:00:00Z",
+	BucketMonth: "%Y-%m-01T00:00:00Z",
+}
+
+// TrafficBucket - Bitta oraliqdagi jami traffic
+type TrafficBucket struct {
+	Start         time.Time `json:"start"`
+	BytesReceived int64     `json:"bytes_received"`
+	BytesSent     int64     `json:"bytes_sent"`
+	TotalBytes    int64     `json:"total_bytes"`
+}
+
+// GetTrafficHistory - Client traffic tarixini [from, to) oralig'ida bucket bo'yicha guruhlab olish
+func GetTrafficHistory(clientID uint, from, to time.Time, bucket string) ([]TrafficBucket, error) {
+	format, ok := bucketFormats[bucket]
+	if !ok {
+		return nil, fmt.Errorf("noma'lum bucket: %s", bucket)
+	}
+
+	var rows []struct {
+		Bucket        string
+		BytesReceived int64
+		BytesSent     int64
+	}
+	err := DB.Model(&models.TrafficSample{}).
+		Select("strftime(?, sampled_at) AS bucket, SUM(bytes_received) AS bytes_received, SUM(bytes_sent) AS bytes_sent", format).
+		Where("client_id = ? AND sampled_at >= ? AND sampled_at < ?", clientID, from.UTC(), to.UTC()).
+		Group("bucket").
+		Order("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]TrafficBucket, 0, len(rows))
+	for _, row := range rows {
+		start, err := time.Parse(time.RFC3339, row.Bucket)
+		if err != nil {
+			return nil, fmt.Errorf("bucket vaqtini o'qishda xatolik: %v", err)
+		}
+
+		buckets = append(buckets, TrafficBucket{
+			Start:         start,
+			BytesReceived: row.BytesReceived,
+			BytesSent:     row.BytesSent,
+			TotalBytes:    row.BytesReceived + row.BytesSent,
+		})
+	}
+
+	return buckets, nil
+}
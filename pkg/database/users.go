@@ -0,0 +1,151 @@
+package database
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wireguard-vpn-client-creater/pkg/models"
+)
+
+// sessionTouchInterval - last_seen_at ni har so'rovda emas, shu oraliqda bir marta yangilash
+const sessionTouchInterval = time.Minute
+
+// Foydalanuvchi va sessiya xatoliklari
+var (
+	ErrUserNotFound    = errors.New("foydalanuvchi topilmadi")
+	ErrSessionNotFound = errors.New("sessiya topilmadi yoki muddati o'tgan")
+)
+
+// CreateAdminUser - Yangi administrator foydalanuvchisini saqlash
+func CreateAdminUser(user *models.AdminUser) error {
+	return DB.Create(user).Error
+}
+
+// GetAdminUserByUsername - Foydalanuvchini username bo'yicha olish
+func GetAdminUserByUsername(username string) (*models.AdminUser, error) {
+	var user models.AdminUser
+	err := DB.Where("username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return &user, err
+}
+
+// GetAdminUserByID - Foydalanuvchini ID bo'yicha olish
+func GetAdminUserByID(id uint) (*models.AdminUser, error) {
+	var user models.AdminUser
+	err := DB.First(&user, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return &user, err
+}
+
+// GetAllAdminUsers - Barcha foydalanuvchilarni olish
+func GetAllAdminUsers() ([]models.AdminUser, error) {
+	var users []models.AdminUser
+	err := DB.Order("id").Find(&users).Error
+	return users, err
+}
+
+// UpdateAdminUser - Foydalanuvchi o'zgarishlarini saqlash
+func UpdateAdminUser(user *models.AdminUser) error {
+	return DB.Save(user).Error
+}
+
+// AcceptTOTPStep - TOTP oralig'ini foydalanuvchining oxirgi qabul qilingan oralig'i sifatida saqlash.
+// Oraliq avvalgisidan katta bo'lmasa (kod allaqachon ishlatilgan, shu jumladan parallel so'rovda) false qaytaradi.
+func AcceptTOTPStep(userID uint, step int64) (bool, error) {
+	result := DB.Model(&models.AdminUser{}).Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+// DeleteAdminUser - Foydalanuvchini va uning barcha sessiyalarini o'chirish
+func DeleteAdminUser(id uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", id).Delete(&models.UserSession{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.AdminUser{}, id).Error
+	})
+}
+
+// CreateUserSession - Foydalanuvchi uchun yangi sessiya yaratish. Sessiya tokeni faqat shu yerda qaytariladi.
+func CreateUserSession(user *models.AdminUser, ttl time.Duration, sourceIP, userAgent string) (string, *models.UserSession, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, fmt.Errorf("sessiya tokeni yaratishda xatolik: %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	session := &models.UserSession{
+		TokenHash:  HashAPIKey(token),
+		UserID:     user.ID,
+		ExpiresAt:  now.Add(ttl),
+		LastSeenAt: now,
+		SourceIP:   sourceIP,
+		UserAgent:  userAgent,
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.AdminUser{}).Where("id = ?", user.ID).Update("last_login_at", now).Error
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	user.LastLoginAt = &now
+	return token, session, nil
+}
+
+// GetSessionUser - Sessiya tokeni bo'yicha faol sessiya va uning foydalanuvchisini olish
+func GetSessionUser(token string) (*models.UserSession, *models.AdminUser, error) {
+	var session models.UserSession
+	err := DB.Where("token_hash = ? AND expires_at > ?", HashAPIKey(token), time.Now()).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := GetAdminUserByID(session.UserID)
+	if errors.Is(err, ErrUserNotFound) || (err == nil && user.DisabledAt != nil) {
+		return nil, nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if time.Since(session.LastSeenAt) >= sessionTouchInterval {
+		session.LastSeenAt = time.Now()
+		DB.Model(&session).Update("last_seen_at", session.LastSeenAt)
+	}
+
+	return &session, user, nil
+}
+
+// DeleteUserSession - Sessiyani tugatish (logout)
+func DeleteUserSession(id uint) error {
+	return DB.Delete(&models.UserSession{}, id).Error
+}
+
+// DeleteUserSessions - Foydalanuvchining barcha sessiyalarini tugatish (parol o'zgarganda, bloklanganda)
+func DeleteUserSessions(userID uint) error {
+	return DB.Where("user_id = ?", userID).Delete(&models.UserSession{}).Error
+}
+
+// DeleteExpiredSessions - Muddati o'tgan sessiyalarni tozalash
+func DeleteExpiredSessions() error {
+	return DB.Where("expires_at <= ?", time.Now()).Delete(&models.UserSession{}).Error
+}
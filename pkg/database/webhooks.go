@@ -0,0 +1,25 @@
+package database
+
+import (
+	"time"
+
+	"wireguard-vpn-client-creater/pkg/models"
+)
+
+// CreateWebhookDelivery - Yangi webhook yetkazishini navbatga qo'yish
+func CreateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	return DB.Create(delivery).Error
+}
+
+// GetDueWebhookDeliveries - Yuborish vaqti kelgan webhooklarni olish
+func GetDueWebhookDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := DB.Where("next_attempt_at IS NOT NULL AND next_attempt_at <= ?", now).
+		Order("next_attempt_at").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// UpdateWebhookDelivery - Yetkazish urinishi natijasini saqlash
+func UpdateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	return DB.Save(delivery).Error
+}
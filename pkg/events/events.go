@@ -0,0 +1,156 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"wireguard-vpn-client-creater/pkg/models"
+)
+
+// Event turlari
+const (
+	ClientCreated   = "client.created"
+	ClientDeleted   = "client.deleted"
+	ClientExpired   = "client.expired"
+	ClientSuspended = "client.suspended"
+	ClientResumed   = "client.resumed"
+	ClientOnline    = "client.online"
+	ClientOffline   = "client.offline"
+	ClientTraffic   = "client.traffic"
+
+	ClientExpiringSoon  = "client.expiring_soon"
+	ClientQuotaExceeded = "client.quota_exceeded"
+	AuthIPBlocked       = "auth.ip_blocked"
+)
+
+// Event - Obunachilarga yuboriladigan hodisa
+type Event struct {
+	ID       uint64      `json:"id"`
+	Type     string      `json:"type"`
+	ClientID uint        `json:"client_id,omitempty"`
+	Time     time.Time   `json:"time"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// ClientInfo - Hodisa uchun client ma'lumotlari (kalitlarsiz)
+type ClientInfo struct {
+	ID            uint              `json:"id"`
+	Description   string            `json:"description"`
+	Type          models.ClientType `json:"type"`
+	Address       string            `json:"address"`
+	AddressV6     string            `json:"address_v6,omitempty"`
+	Active        bool              `json:"active"`
+	ExpiresAt     *time.Time        `json:"expires_at,omitempty"`
+	SuspendReason string            `json:"suspend_reason,omitempty"`
+	QuotaBytes    int64             `json:"quota_bytes,omitempty"`
+	UsedBytes     int64             `json:"used_bytes,omitempty"`
+}
+
+// NewClientInfo - Client modelidan hodisa ma'lumotlarini yaratish
+func NewClientInfo(client *models.WireguardClient) ClientInfo {
+	return ClientInfo{
+		ID:            client.ID,
+		Description:   client.Description,
+		Type:          client.Type,
+		Address:       client.Address,
+		AddressV6:     client.AddressV6,
+		Active:        client.Active,
+		ExpiresAt:     client.ExpiresAt,
+		SuspendReason: client.SuspendReason,
+		QuotaBytes:    client.QuotaBytes,
+		UsedBytes:     client.UsedBytes,
+	}
+}
+
+// subscriberBuffer - Har bir obunachi uchun navbat hajmi
+const subscriberBuffer = 64
+
+// Bus - Hodisalarni obunachilarga tarqatuvchi shina
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+	hooks       []func(Event)
+	lastID      uint64
+}
+
+// NewBus - Yangi Bus yaratish
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[chan Event]struct{})}
+}
+
+// Default - Dastur bo'ylab ishlatiladigan umumiy shina
+var Default = NewBus()
+
+// Publish - Hodisani avval hooklarga, so'ng barcha obunachilarga yuborish.
+// Hooklar publisher goroutinesida chaqiriladi va hodisa ularga albatta yetadi.
+// Sekin obunachi navbati to'lgan bo'lsa, hodisa unga yuborilmaydi (publisher bloklanmaydi).
+func (b *Bus) Publish(eventType string, clientID uint, data interface{}) {
+	event := Event{
+		ID:       atomic.AddUint64(&b.lastID, 1),
+		Type:     eventType,
+		ClientID: clientID,
+		Time:     time.Now().UTC(),
+		Data:     data,
+	}
+
+	b.mu.RLock()
+	hooks := b.hooks
+	b.mu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(event)
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// AddHook - Har bir hodisada sinxron chaqiriladigan funksiyani qo'shish.
+// Hodisa yo'qolmasligi kerak bo'lgan qabul qiluvchilar (masalan webhook navbati) uchun; hook tez ishlashi kerak.
+func (b *Bus) AddHook(hook func(Event)) {
+	b.mu.Lock()
+	b.hooks = append(b.hooks, hook)
+	b.mu.Unlock()
+}
+
+// Subscribe - Hodisalarga obuna bo'lish. Qaytarilgan funksiya obunani bekor qiladi.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish - Umumiy shinaga hodisa yuborish
+func Publish(eventType string, clientID uint, data interface{}) {
+	Default.Publish(eventType, clientID, data)
+}
+
+// PublishClient - Client hodisasini uning ma'lumotlari bilan yuborish
+func PublishClient(eventType string, client *models.WireguardClient) {
+	Default.Publish(eventType, client.ID, NewClientInfo(client))
+}
+
+// Subscribe - Umumiy shinadagi hodisalarga obuna bo'lish
+func Subscribe() (<-chan Event, func()) {
+	return Default.Subscribe()
+}
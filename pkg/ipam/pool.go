@@ -0,0 +1,350 @@
+package ipam
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"wireguard-vpn-client-creater/pkg/config"
+	"wireguard-vpn-client-creater/pkg/models"
+)
+
+// ErrPoolExhausted - Pooldagi barcha manzillar band bo'lganda qaytariladigan xatolik
+var ErrPoolExhausted = errors.New("IP manzillar pooli to'lgan")
+
+// Pool - Bitta client turi uchun IP manzillar pooli
+type Pool struct {
+	Name     string
+	Prefix   netip.Prefix
+	PrefixV6 netip.Prefix // Sozlanmagan bo'lsa IsValid() false qaytaradi
+	reserved []addrRange
+}
+
+// addrRange - Yopiq IP manzillar oralig'i [from, to]
+type addrRange struct {
+	from netip.Addr
+	to   netip.Addr
+}
+
+// contains - Manzil oraliqqa tegishli ekanligini tekshirish
+func (r addrRange) contains(addr netip.Addr) bool {
+	return r.from.Compare(addr) <= 0 && addr.Compare(r.to) <= 0
+}
+
+// Global poollar (client turi -> pool)
+var pools map[models.ClientType]*Pool
+
+// defaultPools - Konfiguratsiyada poollar ko'rsatilmagan bo'lsa ishlatiladigan poollar
+var defaultPools = map[string]config.PoolConfig{
+	string(models.ClientTypeNormal): {CIDR: "10.7.0.0/16", Reserved: []string{"10.7.0.1"}},
+	string(models.ClientTypeVIP):    {CIDR: "10.77.0.0/16", Reserved: []string{"10.77.0.1"}},
+}
+
+// InitPools - Konfiguratsiyadan global poollarni yuklash
+func InitPools() error {
+	poolConfigs := config.Config.Wireguard.Pools
+	if len(poolConfigs) == 0 {
+		poolConfigs = defaultPools
+	}
+
+	loaded, err := LoadPools(poolConfigs)
+	if err != nil {
+		return err
+	}
+
+	pools = loaded
+	return nil
+}
+
+// LoadPools - Pool konfiguratsiyalarini tekshirib, Pool obyektlariga o'tkazish
+func LoadPools(poolConfigs map[string]config.PoolConfig) (map[models.ClientType]*Pool, error) {
+	result := make(map[models.ClientType]*Pool, len(poolConfigs))
+	for name, poolConfig := range poolConfigs {
+		clientType := models.ClientType(name)
+		if clientType != models.ClientTypeNormal && clientType != models.ClientTypeVIP {
+			return nil, fmt.Errorf("noma'lum client turi uchun pool: %s", name)
+		}
+
+		pool, err := NewPool(name, poolConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		// Poollar bir-biri bilan kesishmasligi kerak
+		for _, other := range result {
+			if pool.Prefix.Overlaps(other.Prefix) {
+				return nil, fmt.Errorf("%s va %s poollari kesishadi", pool.Name, other.Name)
+			}
+			if pool.PrefixV6.IsValid() && other.PrefixV6.IsValid() && pool.PrefixV6.Overlaps(other.PrefixV6) {
+				return nil, fmt.Errorf("%s va %s poollarining IPv6 prefixlari kesishadi", pool.Name, other.Name)
+			}
+		}
+
+		result[clientType] = pool
+	}
+
+	return result, nil
+}
+
+// NewPool - Konfiguratsiyadan yangi pool yaratish
+func NewPool(name string, poolConfig config.PoolConfig) (*Pool, error) {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(poolConfig.CIDR))
+	if err != nil {
+		return nil, fmt.Errorf("%s pooli CIDR noto'g'ri: %v", name, err)
+	}
+	if !prefix.Addr().Is4() {
+		return nil, fmt.Errorf("%s pooli IPv4 CIDR bo'lishi kerak", name)
+	}
+
+	pool := &Pool{Name: name, Prefix: prefix.Masked()}
+
+	// IPv6 prefix ixtiyoriy. Har bir clientning IPv6 manzili IPv4 manzilining
+	// pooldagi tartib raqamidan hosil qilinadi, shuning uchun prefix IPv4 pooldan kichik bo'lmasligi kerak
+	if ipv6CIDR := strings.TrimSpace(poolConfig.IPv6CIDR); ipv6CIDR != "" {
+		prefixV6, err := netip.ParsePrefix(ipv6CIDR)
+		if err != nil {
+			return nil, fmt.Errorf("%s pooli IPv6 CIDR noto'g'ri: %v", name, err)
+		}
+		if !prefixV6.Addr().Is6() || prefixV6.Addr().Is4In6() {
+			return nil, fmt.Errorf("%s pooli ipv6_cidr IPv6 prefix bo'lishi kerak", name)
+		}
+		if 128-prefixV6.Bits() < 32-prefix.Bits() {
+			return nil, fmt.Errorf("%s pooli IPv6 prefixi IPv4 pooldan kichik", name)
+		}
+		pool.PrefixV6 = prefixV6.Masked()
+	}
+	for _, entry := range poolConfig.Reserved {
+		reserved, err := parseRange(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s pooli reserved qiymati noto'g'ri: %v", name, err)
+		}
+		pool.reserved = append(pool.reserved, reserved)
+	}
+
+	return pool, nil
+}
+
+// PoolFor - Client turi uchun poolni olish
+func PoolFor(clientType models.ClientType) (*Pool, error) {
+	pool, exists := pools[clientType]
+	if !exists {
+		return nil, fmt.Errorf("%s client turi uchun pool sozlanmagan", clientType)
+	}
+	return pool, nil
+}
+
+// Pools - Barcha sozlangan poollarni olish
+func Pools() map[models.ClientType]*Pool {
+	return pools
+}
+
+// FirstHost - Pooldagi birinchi foydalanish mumkin bo'lgan manzil (network manzildan keyingisi)
+func (p *Pool) FirstHost() netip.Addr {
+	if p.Prefix.Bits() >= 31 {
+		return p.Prefix.Addr()
+	}
+	return p.Prefix.Addr().Next()
+}
+
+// LastHost - Pooldagi oxirgi foydalanish mumkin bo'lgan manzil (broadcast manzildan oldingisi)
+func (p *Pool) LastHost() netip.Addr {
+	last := lastAddr(p.Prefix)
+	if p.Prefix.Bits() >= 31 {
+		return last
+	}
+	return last.Prev()
+}
+
+// IsReserved - Manzil reserved oraliqlarga tegishli ekanligini tekshirish
+func (p *Pool) IsReserved(addr netip.Addr) bool {
+	for _, reserved := range p.reserved {
+		if reserved.contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUsable - Manzil pool ichida, network/broadcast emas va reserved emasligini tekshirish
+func (p *Pool) IsUsable(addr netip.Addr) bool {
+	if !p.Prefix.Contains(addr) {
+		return false
+	}
+	if addr.Compare(p.FirstHost()) < 0 || addr.Compare(p.LastHost()) > 0 {
+		return false
+	}
+	return !p.IsReserved(addr)
+}
+
+// FirstIndex - Birinchi foydalanish mumkin bo'lgan manzilning tartib raqami
+func (p *Pool) FirstIndex() uint32 {
+	index, _ := p.IndexOf(p.FirstHost())
+	return index
+}
+
+// LastIndex - Oxirgi foydalanish mumkin bo'lgan manzilning tartib raqami
+func (p *Pool) LastIndex() uint32 {
+	index, _ := p.IndexOf(p.LastHost())
+	return index
+}
+
+// IndexOf - Manzilning pool boshidan (network manzildan) tartib raqami
+func (p *Pool) IndexOf(addr netip.Addr) (uint32, bool) {
+	if !addr.Is4() || !p.Prefix.Contains(addr) {
+		return 0, false
+	}
+
+	network := p.Prefix.Addr().As4()
+	host := addr.As4()
+	return binary.BigEndian.Uint32(host[:]) - binary.BigEndian.Uint32(network[:]), true
+}
+
+// AddrAt - Tartib raqami bo'yicha IPv4 manzilni olish
+func (p *Pool) AddrAt(index uint32) netip.Addr {
+	network := p.Prefix.Addr().As4()
+	var host [4]byte
+	binary.BigEndian.PutUint32(host[:], binary.BigEndian.Uint32(network[:])+index)
+	return netip.AddrFrom4(host)
+}
+
+// IPv6At - Tartib raqami bo'yicha IPv6 manzilni olish (pool IPv6 prefixi + tartib raqami)
+func (p *Pool) IPv6At(index uint32) (netip.Addr, bool) {
+	if !p.PrefixV6.IsValid() {
+		return netip.Addr{}, false
+	}
+
+	// Tartib raqamini IPv6 prefixning oxirgi 32 bitiga qo'shish
+	v6 := p.PrefixV6.Addr().As16()
+	low := binary.BigEndian.Uint32(v6[12:]) | index
+	binary.BigEndian.PutUint32(v6[12:], low)
+
+	return netip.AddrFrom16(v6), true
+}
+
+// AddressesAt - Tartib raqami bo'yicha client manzillarini CIDR ko'rinishida olish.
+// Pool uchun IPv6 prefix sozlanmagan bo'lsa ipv6 bo'sh satr bo'ladi.
+func (p *Pool) AddressesAt(index uint32) (ipv4 string, ipv6 string) {
+	ipv4 = netip.PrefixFrom(p.AddrAt(index), 32).String()
+	if v6, ok := p.IPv6At(index); ok {
+		ipv6 = netip.PrefixFrom(v6, 128).String()
+	}
+	return ipv4, ipv6
+}
+
+// UsableCount - Pooldagi clientlarga berilishi mumkin bo'lgan manzillar soni
+func (p *Pool) UsableCount() uint64 {
+	first, last := p.FirstIndex(), p.LastIndex()
+
+	// Reserved oraliqlarni [first, last] ga qirqib, tartib raqamlari ko'rinishiga o'tkazish
+	var ranges [][2]uint32
+	for _, r := range p.reserved {
+		if !r.from.Is4() || !r.to.Is4() {
+			continue
+		}
+		if r.to.Compare(p.FirstHost()) < 0 || r.from.Compare(p.LastHost()) > 0 {
+			continue
+		}
+
+		from, to := first, last
+		if index, ok := p.IndexOf(r.from); ok && index > first {
+			from = index
+		}
+		if index, ok := p.IndexOf(r.to); ok && index < last {
+			to = index
+		}
+		ranges = append(ranges, [2]uint32{from, to})
+	}
+
+	// Kesishgan oraliqlarni birlashtirib, reserved manzillar sonini hisoblash
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	var reserved uint64
+	for i := 0; i < len(ranges); {
+		from, to := ranges[i][0], ranges[i][1]
+		j := i + 1
+		for ; j < len(ranges) && uint64(ranges[j][0]) <= uint64(to)+1; j++ {
+			if ranges[j][1] > to {
+				to = ranges[j][1]
+			}
+		}
+		reserved += uint64(to-from) + 1
+		i = j
+	}
+
+	return uint64(last-first) + 1 - reserved
+}
+
+// NextUsableIndex - from dan boshlab reserved bo'lmagan birinchi tartib raqamini topish
+func (p *Pool) NextUsableIndex(from uint32) (uint32, bool) {
+	if from < p.FirstIndex() {
+		from = p.FirstIndex()
+	}
+
+	last := p.LastIndex()
+	for index := from; index <= last; index++ {
+		if !p.IsReserved(p.AddrAt(index)) {
+			return index, true
+		}
+		// uint32 to'lib ketishidan himoya
+		if index == last {
+			break
+		}
+	}
+
+	return 0, false
+}
+
+// parseRange - "10.0.0.1", "10.0.0.0/24" yoki "10.0.0.1-10.0.0.9" ko'rinishidagi qiymatni o'qish
+func parseRange(entry string) (addrRange, error) {
+	entry = strings.TrimSpace(entry)
+
+	if from, to, found := strings.Cut(entry, "-"); found {
+		fromAddr, err := netip.ParseAddr(strings.TrimSpace(from))
+		if err != nil {
+			return addrRange{}, err
+		}
+		toAddr, err := netip.ParseAddr(strings.TrimSpace(to))
+		if err != nil {
+			return addrRange{}, err
+		}
+		if toAddr.Less(fromAddr) {
+			return addrRange{}, fmt.Errorf("oraliq noto'g'ri: %s", entry)
+		}
+		return addrRange{from: fromAddr, to: toAddr}, nil
+	}
+
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return addrRange{}, err
+		}
+		prefix = prefix.Masked()
+		return addrRange{from: prefix.Addr(), to: lastAddr(prefix)}, nil
+	}
+
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return addrRange{}, err
+	}
+	return addrRange{from: addr, to: addr}, nil
+}
+
+// lastAddr - Prefixdagi oxirgi manzil (IPv4 uchun broadcast manzil)
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Masked().Addr().AsSlice()
+	hostBits := len(bytes)*8 - prefix.Bits()
+	for i := len(bytes) - 1; i >= 0 && hostBits > 0; i-- {
+		if hostBits >= 8 {
+			bytes[i] = 0xff
+			hostBits -= 8
+		} else {
+			bytes[i] |= byte(1<<hostBits) - 1
+			hostBits = 0
+		}
+	}
+
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
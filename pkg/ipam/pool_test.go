@@ -0,0 +1,192 @@
+package ipam
+
+import (
+	"net/netip"
+	"testing"
+
+	"wireguard-vpn-client-creater/pkg/config"
+)
+
+func mustPool(t *testing.T, poolConfig config.PoolConfig) *Pool {
+	t.Helper()
+	pool, err := NewPool("test", poolConfig)
+	if err != nil {
+		t.Fatalf("NewPool(%+v) xatolik: %v", poolConfig, err)
+	}
+	return pool
+}
+
+func TestNewPoolValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  config.PoolConfig
+		wantErr bool
+	}{
+		{"ipv4", config.PoolConfig{CIDR: "10.7.0.0/24"}, false},
+		{"host bitlari tozalanadi", config.PoolConfig{CIDR: "10.7.0.5/24"}, false},
+		{"noto'g'ri CIDR", config.PoolConfig{CIDR: "10.7.0.0/33"}, true},
+		{"ipv6 asosiy pool", config.PoolConfig{CIDR: "fd00::/64"}, true},
+		{"ipv6 prefix", config.PoolConfig{CIDR: "10.7.0.0/24", IPv6CIDR: "fd00:7::/64"}, false},
+		{"ipv6 o'rniga ipv4", config.PoolConfig{CIDR: "10.7.0.0/24", IPv6CIDR: "10.8.0.0/24"}, true},
+		{"ipv6 prefix juda kichik", config.PoolConfig{CIDR: "10.7.0.0/16", IPv6CIDR: "fd00:7::/120"}, true},
+		{"reserved oraliq", config.PoolConfig{CIDR: "10.7.0.0/24", Reserved: []string{"10.7.0.1-10.7.0.9"}}, false},
+		{"teskari oraliq", config.PoolConfig{CIDR: "10.7.0.0/24", Reserved: []string{"10.7.0.9-10.7.0.1"}}, true},
+		{"noto'g'ri reserved", config.PoolConfig{CIDR: "10.7.0.0/24", Reserved: []string{"10.7.0"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPool("test", tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewPool() xatolik = %v, kutilgan xatolik: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPoolIsUsable(t *testing.T) {
+	pool := mustPool(t, config.PoolConfig{
+		CIDR:     "10.7.0.0/24",
+		Reserved: []string{"10.7.0.1", "10.7.0.10-10.7.0.19", "10.7.0.128/30"},
+	})
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"10.7.0.0", false},   // network
+		{"10.7.0.1", false},   // reserved IP
+		{"10.7.0.2", true},    // birinchi bo'sh manzil
+		{"10.7.0.9", true},    // oraliqdan oldin
+		{"10.7.0.10", false},  // oraliq boshi
+		{"10.7.0.19", false},  // oraliq oxiri
+		{"10.7.0.20", true},   // oraliqdan keyin
+		{"10.7.0.130", false}, // reserved CIDR
+		{"10.7.0.132", true},  // CIDR dan keyin
+		{"10.7.0.254", true},  // oxirgi host
+		{"10.7.0.255", false}, // broadcast
+		{"10.7.1.1", false},   // pooldan tashqari
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := pool.IsUsable(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("IsUsable(%s) = %v, kutilgan %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolUsableCount(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.PoolConfig
+		want   uint64
+	}{
+		{"/24", config.PoolConfig{CIDR: "10.7.0.0/24"}, 254},
+		{"/16 standart", config.PoolConfig{CIDR: "10.7.0.0/16", Reserved: []string{"10.7.0.1"}}, 65533},
+		{"/31 network va broadcastsiz", config.PoolConfig{CIDR: "10.7.0.0/31"}, 2},
+		{"/32", config.PoolConfig{CIDR: "10.7.0.1/32"}, 1},
+		{"kesishgan oraliqlar", config.PoolConfig{CIDR: "10.7.0.0/24", Reserved: []string{"10.7.0.1-10.7.0.10", "10.7.0.5-10.7.0.20", "10.7.0.21"}}, 233},
+		{"pooldan chiqib ketgan oraliq", config.PoolConfig{CIDR: "10.7.0.0/24", Reserved: []string{"10.6.255.0-10.7.0.4", "10.7.0.250-10.7.1.10"}}, 245},
+		{"pooldan tashqari oraliq", config.PoolConfig{CIDR: "10.7.0.0/24", Reserved: []string{"10.8.0.0/16"}}, 254},
+		{"butun pool reserved", config.PoolConfig{CIDR: "10.7.0.0/24", Reserved: []string{"10.7.0.0/24"}}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mustPool(t, tt.config).UsableCount(); got != tt.want {
+				t.Errorf("UsableCount() = %d, kutilgan %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolNextUsableIndex(t *testing.T) {
+	pool := mustPool(t, config.PoolConfig{
+		CIDR:     "10.7.0.0/28",
+		Reserved: []string{"10.7.0.1-10.7.0.3", "10.7.0.14"},
+	})
+
+	tests := []struct {
+		from   uint32
+		want   uint32
+		wantOK bool
+	}{
+		{0, 4, true},   // network va reserved o'tkazib yuboriladi
+		{4, 4, true},   // o'zi bo'sh
+		{9, 9, true},   // o'rtadagi manzil
+		{13, 13, true}, // reserveddan oldingi oxirgi bo'sh
+		{14, 0, false}, // qolganlari reserved
+		{15, 0, false}, // broadcast
+	}
+
+	for _, tt := range tests {
+		got, ok := pool.NextUsableIndex(tt.from)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("NextUsableIndex(%d) = %d, %v, kutilgan %d, %v", tt.from, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestPoolAddressesAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   config.PoolConfig
+		index    uint32
+		wantIPv4 string
+		wantIPv6 string
+	}{
+		{"faqat ipv4", config.PoolConfig{CIDR: "10.7.0.0/16"}, 258, "10.7.1.2/32", ""},
+		{"ipv6 bilan", config.PoolConfig{CIDR: "10.7.0.0/16", IPv6CIDR: "fd00:7::/64"}, 258, "10.7.1.2/32", "fd00:7::102/128"},
+		{"ipv6 prefix oxirgi 32 bitgacha", config.PoolConfig{CIDR: "10.7.0.0/24", IPv6CIDR: "fd00:7::ab00:0/120"}, 5, "10.7.0.5/32", "fd00:7::ab00:5/128"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := mustPool(t, tt.config)
+			ipv4, ipv6 := pool.AddressesAt(tt.index)
+			if ipv4 != tt.wantIPv4 || ipv6 != tt.wantIPv6 {
+				t.Errorf("AddressesAt(%d) = %q, %q, kutilgan %q, %q", tt.index, ipv4, ipv6, tt.wantIPv4, tt.wantIPv6)
+			}
+
+			index, ok := pool.IndexOf(pool.AddrAt(tt.index))
+			if !ok || index != tt.index {
+				t.Errorf("IndexOf(AddrAt(%d)) = %d, %v", tt.index, index, ok)
+			}
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		entry    string
+		wantFrom string
+		wantTo   string
+		wantErr  bool
+	}{
+		{"10.7.0.1", "10.7.0.1", "10.7.0.1", false},
+		{" 10.7.0.1 - 10.7.0.9 ", "10.7.0.1", "10.7.0.9", false},
+		{"10.7.0.5/30", "10.7.0.4", "10.7.0.7", false},
+		{"fd00::/126", "fd00::", "fd00::3", false},
+		{"10.7.0.9-10.7.0.1", "", "", true},
+		{"10.7.0.1-", "", "", true},
+		{"10.7.0.0/40", "", "", true},
+		{"localhost", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			r, err := parseRange(tt.entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRange(%q) xatolik = %v, kutilgan xatolik: %v", tt.entry, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if r.from.String() != tt.wantFrom || r.to.String() != tt.wantTo {
+				t.Errorf("parseRange(%q) = [%s, %s], kutilgan [%s, %s]", tt.entry, r.from, r.to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
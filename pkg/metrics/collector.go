@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"wireguard-vpn-client-creater/pkg/database"
+	"wireguard-vpn-client-creater/pkg/models"
+	"wireguard-vpn-client-creater/pkg/wireguard"
+)
+
+var (
+	peerLabels = []string{"client_id", "description", "type"}
+
+	peerReceivedDesc = prometheus.NewDesc(namespace+"_peer_received_bytes_total",
+		"Peerdan qabul qilingan baytlar (interface hisoblagichi).", peerLabels, nil)
+	peerSentDesc = prometheus.NewDesc(namespace+"_peer_sent_bytes_total",
+		"Peerga yuborilgan baytlar (interface hisoblagichi).", peerLabels, nil)
+	peerHandshakeDesc = prometheus.NewDesc(namespace+"_peer_latest_handshake_seconds",
+		"Peerning oxirgi handshake vaqti (unix soniyalarda, 0 = hali ulanmagan).", peerLabels, nil)
+
+	clientsTotalDesc = prometheus.NewDesc(namespace+"_clients_total",
+		"Databasedagi clientlar soni.", nil, nil)
+	clientsActiveDesc = prometheus.NewDesc(namespace+"_clients_active",
+		"Faol (to'xtatilmagan) clientlar soni.", nil, nil)
+	clientsExpiredDesc = prometheus.NewDesc(namespace+"_clients_expired",
+		"Muddati o'tgan, lekin hali o'chirilmagan clientlar soni.", nil, nil)
+
+	poolSizeDesc = prometheus.NewDesc(namespace+"_pool_size",
+		"Pooldagi berilishi mumkin bo'lgan manzillar soni.", []string{"pool"}, nil)
+	poolAllocatedDesc = prometheus.NewDesc(namespace+"_pool_allocated",
+		"Pooldan clientlarga berilgan manzillar soni.", []string{"pool"}, nil)
+	poolQuarantinedDesc = prometheus.NewDesc(namespace+"_pool_quarantined",
+		"Karantindagi manzillar soni.", []string{"pool"}, nil)
+	poolUtilisationDesc = prometheus.NewDesc(namespace+"_pool_utilisation_ratio",
+		"Pool bandligi (0-1).", []string{"pool"}, nil)
+
+	blockedIPsDesc = prometheus.NewDesc(namespace+"_blocked_ips",
+		"Hozirda bloklangan IP manzillar soni.", nil, nil)
+
+	scrapeErrorDesc = prometheus.NewDesc(namespace+"_scrape_error",
+		"Oxirgi o'qishda xatolik bo'lgan bo'lsa 1.", []string{"source"}, nil)
+)
+
+// Collector - Har bir so'rovda backend va databasedan holatni o'qiydigan collector
+type Collector struct {
+	backend    wireguard.Backend
+	blockedIPs func() int // IP bloklash o'chirilgan bo'lsa nil
+}
+
+// NewCollector - Yangi Collector yaratish
+func NewCollector(backend wireguard.Backend, blockedIPs func() int) *Collector {
+	return &Collector{backend: backend, blockedIPs: blockedIPs}
+}
+
+// Describe - prometheus.Collector interfeysi
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		peerReceivedDesc, peerSentDesc, peerHandshakeDesc,
+		clientsTotalDesc, clientsActiveDesc, clientsExpiredDesc,
+		poolSizeDesc, poolAllocatedDesc, poolQuarantinedDesc, poolUtilisationDesc,
+		blockedIPsDesc, scrapeErrorDesc,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect - prometheus.Collector interfeysi
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	clients, err := database.GetAllClients()
+	ch <- errorMetric("database", err)
+	if err == nil {
+		c.collectClients(ch, clients)
+
+		peers, err := c.backend.ListPeers()
+		ch <- errorMetric("wireguard", err)
+		if err == nil {
+			c.collectPeers(ch, clients, peers)
+		}
+	}
+
+	usage, err := database.GetPoolUsage()
+	ch <- errorMetric("pools", err)
+	if err == nil {
+		for _, pool := range usage {
+			ch <- prometheus.MustNewConstMetric(poolSizeDesc, prometheus.GaugeValue, float64(pool.Size), pool.Pool)
+			ch <- prometheus.MustNewConstMetric(poolAllocatedDesc, prometheus.GaugeValue, float64(pool.Allocated), pool.Pool)
+			ch <- prometheus.MustNewConstMetric(poolQuarantinedDesc, prometheus.GaugeValue, float64(pool.Quarantined), pool.Pool)
+			ch <- prometheus.MustNewConstMetric(poolUtilisationDesc, prometheus.GaugeValue, pool.Utilisation/100, pool.Pool)
+		}
+	}
+
+	if c.blockedIPs != nil {
+		ch <- prometheus.MustNewConstMetric(blockedIPsDesc, prometheus.GaugeValue, float64(c.blockedIPs()))
+	}
+}
+
+// collectClients - Clientlar soni metrikalari
+func (c *Collector) collectClients(ch chan<- prometheus.Metric, clients []models.WireguardClient) {
+	now := time.Now()
+	var active, expired int
+	for _, client := range clients {
+		if client.Active {
+			active++
+		}
+		if client.ExpiresAt != nil && client.ExpiresAt.Before(now) {
+			expired++
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(clientsTotalDesc, prometheus.GaugeValue, float64(len(clients)))
+	ch <- prometheus.MustNewConstMetric(clientsActiveDesc, prometheus.GaugeValue, float64(active))
+	ch <- prometheus.MustNewConstMetric(clientsExpiredDesc, prometheus.GaugeValue, float64(expired))
+}
+
+// collectPeers - Har bir client peeri uchun traffic va handshake metrikalari
+func (c *Collector) collectPeers(ch chan<- prometheus.Metric, clients []models.WireguardClient, peers []wireguard.PeerStats) {
+	clientMap := make(map[string]*models.WireguardClient, len(clients))
+	for i := range clients {
+		clientMap[clients[i].PublicKey] = &clients[i]
+	}
+
+	for _, peer := range peers {
+		// Databaseda yo'q peerlar drift tekshiruvida ko'rsatiladi
+		client, exists := clientMap[peer.PublicKey]
+		if !exists {
+			continue
+		}
+
+		labels := []string{strconv.FormatUint(uint64(client.ID), 10), client.Description, string(client.Type)}
+
+		var handshake float64
+		if !peer.LatestHandshake.IsZero() {
+			handshake = float64(peer.LatestHandshake.Unix())
+		}
+
+		ch <- prometheus.MustNewConstMetric(peerReceivedDesc, prometheus.CounterValue, float64(peer.BytesReceived), labels...)
+		ch <- prometheus.MustNewConstMetric(peerSentDesc, prometheus.CounterValue, float64(peer.BytesSent), labels...)
+		ch <- prometheus.MustNewConstMetric(peerHandshakeDesc, prometheus.GaugeValue, handshake, labels...)
+	}
+}
+
+// errorMetric - Manba o'qilganda xatolik bo'lganini ko'rsatuvchi metrika
+func errorMetric(source string, err error) prometheus.Metric {
+	value := 0.0
+	if err != nil {
+		log.Printf("Metrikalar: %s ni o'qishda xatolik: %v", source, err)
+		value = 1
+	}
+	return prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, value, source)
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrikalar nomlari uchun umumiy prefiks
+const namespace = "wireguard_vpn"
+
+var (
+	// registry - Barcha metrikalar ro'yxati (default registry ishlatilmaydi)
+	registry = prometheus.NewRegistry()
+
+	// requestDuration - API so'rovlari davomiyligi va statusi
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "API so'rovlarining davomiyligi (soniyalarda).",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// authFailures - Muvaffaqiyatsiz autentifikatsiya urinishlari
+	authFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_auth_failures_total",
+		Help:      "Muvaffaqiyatsiz autentifikatsiya urinishlari soni.",
+	}, []string{"reason"})
+
+	// rateLimited - So'rovlar chegarasi oshgani uchun rad etilgan so'rovlar
+	rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_rate_limited_total",
+		Help:      "So'rovlar chegarasi oshgani uchun rad etilgan so'rovlar soni.",
+	}, []string{"group"})
+)
+
+func init() {
+	registry.MustRegister(
+		requestDuration,
+		authFailures,
+		rateLimited,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Register - Qo'shimcha collectorni ro'yxatga olish
+func Register(collector prometheus.Collector) error {
+	return registry.Register(collector)
+}
+
+// Handler - Metrikalarni Prometheus text formatida qaytaruvchi handler
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Middleware - Har bir so'rov davomiyligi va statusini yozib boruvchi middleware
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Label soni cheklangan bo'lishi uchun haqiqiy yo'l emas, route shabloni ishlatiladi
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		requestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// RecordAuthFailure - Muvaffaqiyatsiz autentifikatsiya urinishini hisoblash
+func RecordAuthFailure(reason string) {
+	authFailures.WithLabelValues(reason).Inc()
+}
+
+// RecordRateLimited - So'rovlar chegarasi oshgani uchun rad etilgan so'rovni hisoblash
+func RecordRateLimited(group string) {
+	rateLimited.WithLabelValues(group).Inc()
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -36,17 +38,229 @@ const (
 type WireguardClient struct {
 	gorm.Model
 	PublicKey     string     `gorm:"uniqueIndex;not null" json:"public_key"`
-	PrivateKey    string     `gorm:"not null" json:"private_key"`
-	PresharedKey  string     `gorm:"not null" json:"preshared_key"`
+	PrivateKey    string     `gorm:"not null" json:"private_key,omitempty"` // Faqat clients:write ruxsati bilan qaytariladi
+	PresharedKey  string     `gorm:"not null" json:"preshared_key,omitempty"`
 	Address       string     `gorm:"uniqueIndex;not null" json:"address"`
+	AddressV6     string     `gorm:"index" json:"address_v6"`
 	Endpoint      string     `json:"endpoint"`
 	DNS           string     `json:"dns"`
 	AllowedIPs    string     `json:"allowed_ips"`
-	ConfigText    string     `json:"config_text"`
+	ConfigText    string     `json:"config_text,omitempty"`
 	LastConnected time.Time  `json:"last_connected"`
 	Description   string     `json:"description"`
 	Active        bool       `gorm:"default:true" json:"active"`
 	Type          ClientType `gorm:"default:'normal'" json:"type"`
 	LifeTime      int        `gorm:"default:0" json:"life_time"` // Soniyalarda, 0 = cheksiz
 	ExpiresAt     *time.Time `json:"expires_at"`
+	SuspendedAt   *time.Time `json:"suspended_at"`
+	SuspendReason string     `json:"suspend_reason"`
+
+	// Muddati tugashi haqida ogohlantirish yuborilgan vaqt (life_time yangilansa bo'shatiladi)
+	ExpiryNotifiedAt *time.Time `json:"-"`
+
+	// Traffic kvotasi
+	QuotaBytes      int64       `gorm:"default:0" json:"quota_bytes"` // Baytlarda, 0 = cheksiz
+	QuotaPeriod     QuotaPeriod `json:"quota_period"`                 // Bo'sh = butun muddat uchun
+	UsedBytes       int64       `gorm:"default:0" json:"used_bytes"`  // Joriy davrda ishlatilgan (rx + tx)
+	PeriodStartedAt *time.Time  `json:"period_started_at"`
+	LastRxBytes     int64       `json:"-"` // Oxirgi o'qilgan interface hisoblagichlari
+	LastTxBytes     int64       `json:"-"`
+}
+
+// QuotaPeriod - Kvota davri
+type QuotaPeriod string
+
+const (
+	// QuotaPeriodNone - Kvota butun muddat uchun, qayta tiklanmaydi
+	QuotaPeriodNone QuotaPeriod = ""
+	// QuotaPeriodMonthly - Kvota har oy belgilangan kunda qayta tiklanadi
+	QuotaPeriodMonthly QuotaPeriod = "monthly"
+)
+
+// SuspendReasonQuota - Kvota tugagani sababli avtomatik to'xtatilgan client sababi
+const SuspendReasonQuota = "quota_exceeded"
+
+// QuotaExceeded - Client kvotasi tugaganini tekshirish
+func (c *WireguardClient) QuotaExceeded() bool {
+	return c.QuotaBytes > 0 && c.UsedBytes >= c.QuotaBytes
+}
+
+// Addresses - Clientning barcha manzillari (IPv4 va mavjud bo'lsa IPv6)
+func (c *WireguardClient) Addresses() []string {
+	addresses := []string{c.Address}
+	if c.AddressV6 != "" {
+		addresses = append(addresses, c.AddressV6)
+	}
+	return addresses
+}
+
+// IPLease - Pooldan clientga berilgan IP manzil yozuvi.
+// (pool, host_index) juftligi unikal, shuning uchun bitta manzil ikki marta berilmaydi.
+type IPLease struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Pool       string     `gorm:"uniqueIndex:idx_ip_lease_pool_index;not null" json:"pool"`
+	HostIndex  uint32     `gorm:"uniqueIndex:idx_ip_lease_pool_index;not null" json:"host_index"`
+	Address    string     `gorm:"not null" json:"address"`
+	AddressV6  string     `json:"address_v6"`
+	ClientID   *uint      `gorm:"index" json:"client_id"`   // Bo'shatilgan manzil uchun NULL
+	ReleasedAt *time.Time `gorm:"index" json:"released_at"` // Client o'chirilgan vaqt
+}
+
+// RollbackFailure - Database va Wireguard o'zgarishini qaytarib bo'lmagan holat yozuvi.
+// Drift tekshiruvi yoki operator tomonidan qo'lda tuzatilishi kerak.
+type RollbackFailure struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Operation   string     `gorm:"not null" json:"operation"` // create, delete, update
+	ClientID    uint       `gorm:"index" json:"client_id"`
+	PublicKey   string     `json:"public_key"`
+	Error       string     `json:"error"`          // Asosiy amal xatoligi
+	RollbackErr string     `json:"rollback_error"` // Qaytarish xatoligi
+	ResolvedAt  *time.Time `gorm:"index" json:"resolved_at"`
+}
+
+// BlockedIP - IP bloklash tizimining saqlangan bloki (dastur qayta ishga tushganda tiklanadi)
+type BlockedIP struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	IP        string     `gorm:"uniqueIndex;not null" json:"ip"` // IP manzil yoki subnet (CIDR)
+	BlockedAt time.Time  `json:"blocked_at"`
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at"` // nil = muddatsiz
+	Reason    string     `json:"reason"`
+	Offense   int        `gorm:"default:0" json:"offense"` // Nechanchi marta bloklangani
+}
+
+// IPAccessRule - Doimiy ruxsat (allow) yoki taqiq (deny) ro'yxatidagi CIDR
+type IPAccessRule struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	List      string    `gorm:"uniqueIndex:idx_ip_access_rule;not null" json:"list"` // allow yoki deny
+	CIDR      string    `gorm:"column:cidr;uniqueIndex:idx_ip_access_rule;not null" json:"cidr"`
+	Comment   string    `json:"comment"`
+	CreatedBy string    `json:"created_by"`
+}
+
+// TrafficSample - Monitor davrida client ishlatgan traffic (interface hisoblagichlari farqi).
+// Hisoblagichlar interface qayta ishga tushganda nolga tushsa ham tarix saqlanib qoladi.
+type TrafficSample struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	ClientID      uint      `gorm:"index:idx_traffic_sample_client_time;not null" json:"client_id"`
+	SampledAt     time.Time `gorm:"index:idx_traffic_sample_client_time;not null" json:"sampled_at"`
+	BytesReceived int64     `json:"bytes_received"`
+	BytesSent     int64     `json:"bytes_sent"`
+}
+
+// ClientSession - Clientning bitta ulanish sessiyasi: uzilishdan keyingi birinchi
+// handshakedan eskirishdan oldingi oxirgi handshakegacha
+type ClientSession struct {
+	ID              uint       `gorm:"primarykey" json:"id"`
+	ClientID        uint       `gorm:"index:idx_client_session_client_start;not null" json:"client_id"`
+	StartedAt       time.Time  `gorm:"index:idx_client_session_client_start;not null" json:"started_at"`
+	LastHandshakeAt time.Time  `json:"last_handshake_at"`
+	EndedAt         *time.Time `gorm:"index" json:"ended_at"` // Faol sessiya uchun NULL
+	Endpoint        string     `json:"endpoint"`              // Clientning tashqi IP manzili va porti
+	BytesReceived   int64      `json:"bytes_received"`
+	BytesSent       int64      `json:"bytes_sent"`
+}
+
+// WebhookDelivery - Webhook endpointiga yuborilishi kerak bo'lgan hodisa.
+// Databasega saqlanadi, shuning uchun dastur qayta ishga tushganda ham yo'qolmaydi.
+type WebhookDelivery struct {
+	ID            uint       `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	URL           string     `gorm:"not null" json:"url"`
+	EventType     string     `gorm:"index;not null" json:"event_type"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at"` // Yetkazilgan yoki bekor qilingan bo'lsa NULL
+	LastStatus    int        `json:"last_status"`
+	LastError     string     `json:"last_error"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	FailedAt      *time.Time `json:"failed_at"` // Barcha urinishlar muvaffaqiyatsiz tugagan vaqt
+}
+
+// AuditLog - O'zgartiruvchi amallar jurnali: kim, qayerdan, qaysi clientni qanday o'zgartirgan
+type AuditLog struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	Actor     string    `gorm:"index;not null" json:"actor"` // Masalan: "token:config", "system:expiration"
+	SourceIP  string    `json:"source_ip"`
+	Action    string    `gorm:"index;not null" json:"action"` // Masalan: "client.create", "client.lifetime.update"
+	ClientID  *uint     `gorm:"index" json:"client_id"`
+	Changes   RawJSON   `gorm:"type:text" json:"changes"` // {"maydon": {"before": .., "after": ..}}
+}
+
+// RawJSON - Databasega matn sifatida saqlanadigan, API da esa JSON obyekt sifatida qaytariladigan qiymat
+type RawJSON string
+
+// MarshalJSON - Qiymatni qo'shtirnoqsiz, tayyor JSON sifatida qaytarish
+func (r RawJSON) MarshalJSON() ([]byte, error) {
+	if r == "" {
+		return []byte("null"), nil
+	}
+	return []byte(r), nil
+}
+
+// APIKey - Nomlangan API kaliti. Kalitning o'zi saqlanmaydi, faqat SHA-256 xeshi.
+type APIKey struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `gorm:"not null" json:"name"`
+	Prefix       string    `gorm:"not null" json:"prefix"` // Kalitni tanib olish uchun birinchi belgilar
+	KeyHash      string    `gorm:"uniqueIndex;not null" json:"-"`
+	Scopes       ScopeList `gorm:"not null" json:"scopes"`
+	APIKeyLimits `gorm:"embedded"`
+	CreatedBy    string     `json:"created_by"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	RevokedAt    *time.Time `gorm:"index" json:"revoked_at"`
+}
+
+// APIKeyLimits - API kalitining route guruhlari bo'yicha o'z so'rovlar chegaralari (minutiga, 0 = konfiguratsiyadagi chegara)
+type APIKeyLimits struct {
+	ReadLimit         int `gorm:"default:0" json:"read_limit"`          // Client va server ma'lumotlarini o'qish
+	WriteLimit        int `gorm:"default:0" json:"write_limit"`         // Clientlarni o'zgartirish
+	AdminLimit        int `gorm:"default:0" json:"admin_limit"`         // Administrator endpointlari
+	ClientCreateLimit int `gorm:"default:0" json:"client_create_limit"` // Yangi clientlar
+}
+
+// ScopeList - Databasega vergul bilan ajratilgan satr sifatida saqlanadigan ruxsatlar ro'yxati
+type ScopeList string
+
+// MarshalJSON - Ruxsatlarni API da massiv sifatida qaytarish
+func (s ScopeList) MarshalJSON() ([]byte, error) {
+	scopes := []string{}
+	for _, scope := range strings.Split(string(s), ",") {
+		if scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return json.Marshal(scopes)
+}
+
+// AdminUser - Web interfeys orqali kiruvchi operator yoki administrator
+type AdminUser struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	Username     string     `gorm:"uniqueIndex;not null" json:"username"`
+	PasswordHash string     `gorm:"not null" json:"-"` // bcrypt
+	Role         string     `gorm:"not null" json:"role"`
+	TOTPSecret   string     `json:"-"` // Ikkinchi faktor kaliti (base32)
+	TOTPEnabled  bool       `json:"totp_enabled"`
+	TOTPLastStep int64      `gorm:"not null;default:0" json:"-"` // Oxirgi qabul qilingan TOTP oralig'i (kodni qayta ishlatishdan himoya)
+	DisabledAt   *time.Time `json:"disabled_at"`
+	LastLoginAt  *time.Time `json:"last_login_at"`
+}
+
+// UserSession - Administrator foydalanuvchisining login sessiyasi (cookie orqali)
+type UserSession struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	TokenHash  string    `gorm:"uniqueIndex;not null" json:"-"`
+	UserID     uint      `gorm:"index;not null" json:"user_id"`
+	ExpiresAt  time.Time `gorm:"index;not null" json:"expires_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	SourceIP   string    `json:"source_ip"`
+	UserAgent  string    `json:"user_agent"`
 }
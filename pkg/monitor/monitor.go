@@ -0,0 +1,67 @@
+package monitor
+
+import (
+	"log"
+	"time"
+
+	"wireguard-vpn-client-creater/pkg/database"
+	"wireguard-vpn-client-creater/pkg/events"
+	"wireguard-vpn-client-creater/pkg/wireguard"
+)
+
+// Start - Peer statistikasini davriy o'qiydigan umumiy pollerni ishga tushirish.
+// Interface har bir davrda faqat bir marta o'qiladi va natija barcha ishlovchilarga uzatiladi.
+func Start(backend wireguard.Backend, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		poll(backend)
+		for range ticker.C {
+			poll(backend)
+		}
+	}()
+}
+
+// poll - Peerlar ro'yxatini bir marta o'qib, barcha ishlovchilarni chaqirish
+func poll(backend wireguard.Backend) {
+	peers, deltas, err := accountTraffic(backend)
+	if err != nil {
+		log.Printf("Monitor: peerlarni o'qishda xatolik: %v", err)
+		return
+	}
+	for _, delta := range deltas {
+		events.Publish(events.ClientTraffic, delta.ClientID, delta)
+	}
+
+	// Oxirgi ulanish vaqti va sessiyalarni yangilash
+	if err := database.TrackSessions(peers, deltas, time.Now()); err != nil {
+		log.Printf("Monitor: sessiyalarni yangilashda xatolik: %v", err)
+	}
+
+	// Kvota davrlarini yangilash va kvotasi tugagan clientlarni to'xtatish
+	resetQuotaPeriods(backend)
+	enforceQuotas(backend)
+}
+
+// accountTraffic - Peerlarni o'qish va clientlar ishlatgan trafficni hisoblash (faqat o'qish xatoligi qaytariladi).
+// Ikkalasi lifecycle amallari bilan bir qulf ostida bajariladi: aks holda o'qishdan keyin
+// to'xtatilib qayta yoqilgan clientning eski hisoblagichi nolga tushirilgan qiymat bilan
+// solishtirilib, traffic ikki marta hisoblanadi.
+func accountTraffic(backend wireguard.Backend) ([]wireguard.PeerStats, []database.TrafficDelta, error) {
+	database.LockPeers()
+	defer database.UnlockPeers()
+
+	peers, err := backend.ListPeers()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deltas, err := database.AccountTraffic(peers)
+	if err != nil {
+		log.Printf("Monitor: trafficni hisoblashda xatolik: %v", err)
+	}
+	return peers, deltas, nil
+}
@@ -0,0 +1,118 @@
+package monitor
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"wireguard-vpn-client-creater/pkg/config"
+	"wireguard-vpn-client-creater/pkg/database"
+	"wireguard-vpn-client-creater/pkg/ipam"
+	"wireguard-vpn-client-creater/pkg/models"
+	"wireguard-vpn-client-creater/pkg/wireguard"
+)
+
+// pausingBackend - Birinchi ListPeers chaqiruvida natijani olib, release yopilguncha qaytarmaydigan MemoryBackend
+type pausingBackend struct {
+	*wireguard.MemoryBackend
+	once    sync.Once
+	listed  chan struct{}
+	release chan struct{}
+}
+
+func (b *pausingBackend) ListPeers() ([]wireguard.PeerStats, error) {
+	peers, err := b.MemoryBackend.ListPeers()
+	b.once.Do(func() {
+		close(b.listed)
+		<-b.release
+	})
+	return peers, err
+}
+
+// setupTestDB - Vaqtinchalik SQLite database va kichik pool bilan test muhitini tayyorlash
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	previousConfig := config.Config
+	t.Cleanup(func() { config.Config = previousConfig })
+
+	config.Config = config.Configuration{}
+	config.Config.Wireguard.Pools = map[string]config.PoolConfig{
+		string(models.ClientTypeNormal): {CIDR: "10.9.0.0/29", Reserved: []string{"10.9.0.1"}},
+	}
+	if err := ipam.InitPools(); err != nil {
+		t.Fatalf("ipam.InitPools xatolik: %v", err)
+	}
+
+	if _, err := database.InitDB(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("InitDB xatolik: %v", err)
+	}
+	database.DB = database.DB.Session(&gorm.Session{Logger: logger.Discard})
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func TestSuspendDuringPollDoesNotDoubleCount(t *testing.T) {
+	setupTestDB(t)
+
+	privateKey, publicKey, err := wireguard.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair xatolik: %v", err)
+	}
+	client := &models.WireguardClient{
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+		Type:       models.ClientTypeNormal,
+		Active:     true,
+	}
+
+	backend := &pausingBackend{
+		MemoryBackend: wireguard.NewMemoryBackend("wg-test"),
+		listed:        make(chan struct{}),
+		release:       make(chan struct{}),
+	}
+	if err := database.ProvisionClient(client, backend); err != nil {
+		t.Fatalf("ProvisionClient xatolik: %v", err)
+	}
+	backend.SetPeerStats(wireguard.PeerStats{PublicKey: publicKey, BytesReceived: 1000, BytesSent: 500})
+
+	// Monitor peerlarni o'qidi, lekin hali hisoblamadi
+	polled := make(chan struct{})
+	go func() {
+		accountTraffic(backend)
+		close(polled)
+	}()
+	<-backend.listed
+
+	// Shu orada client to'xtatilib qayta yoqiladi: hisoblagichlar nolga tushadi
+	lifecycle := make(chan error, 1)
+	go func() {
+		if err := database.SuspendClient(client, "test", backend); err != nil {
+			lifecycle <- err
+			return
+		}
+		lifecycle <- database.ResumeClient(client, backend)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	close(backend.release)
+	<-polled
+	if err := <-lifecycle; err != nil {
+		t.Fatalf("SuspendClient/ResumeClient xatolik: %v", err)
+	}
+
+	var stored models.WireguardClient
+	if err := database.DB.First(&stored, client.ID).Error; err != nil {
+		t.Fatalf("clientni o'qishda xatolik: %v", err)
+	}
+	if stored.UsedBytes != 1500 {
+		t.Errorf("used_bytes = %d, kutilgan 1500", stored.UsedBytes)
+	}
+}
@@ -0,0 +1,115 @@
+package monitor
+
+import (
+	"log"
+	"time"
+
+	"wireguard-vpn-client-creater/pkg/config"
+	"wireguard-vpn-client-creater/pkg/database"
+	"wireguard-vpn-client-creater/pkg/events"
+	"wireguard-vpn-client-creater/pkg/models"
+	"wireguard-vpn-client-creater/pkg/wireguard"
+)
+
+// Kvota tugaganda qo'llanadigan siyosatlar
+const (
+	QuotaPolicySuspend = "suspend"
+	QuotaPolicyDelete  = "delete"
+)
+
+// PeriodStart - now vaqtiga to'g'ri keladigan oylik kvota davrining boshlanishi
+func PeriodStart(now time.Time) time.Time {
+	resetDay := resetDay()
+	start := time.Date(now.Year(), now.Month(), resetDay, 0, 0, 0, 0, now.Location())
+	if now.Before(start) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start
+}
+
+// NextPeriodStart - Keyingi oylik kvota davrining boshlanishi
+func NextPeriodStart(now time.Time) time.Time {
+	return PeriodStart(now).AddDate(0, 1, 0)
+}
+
+// resetDay - Konfiguratsiyadan kvota qayta tiklanadigan kunni olish (1-28)
+func resetDay() int {
+	day := config.Config.Quota.ResetDay
+	if day < 1 {
+		return 1
+	}
+	if day > 28 {
+		// Har oyda mavjud bo'lishi uchun
+		return 28
+	}
+	return day
+}
+
+// resetQuotaPeriods - Yangi davr boshlangan oylik kvotali clientlarning hisobini nolga tushirish
+func resetQuotaPeriods(backend wireguard.Backend) {
+	periodStart := PeriodStart(time.Now())
+
+	clients, err := database.GetClientsDueForReset(periodStart)
+	if err != nil {
+		log.Printf("Kvota: clientlarni olishda xatolik: %v", err)
+		return
+	}
+
+	for i := range clients {
+		client := &clients[i]
+		previous := *client
+		if err := database.ResetClientUsage(client.ID, periodStart); err != nil {
+			log.Printf("Kvota: client %d hisobini yangilashda xatolik: %v", client.ID, err)
+			continue
+		}
+
+		client.UsedBytes = 0
+		client.PeriodStartedAt = &periodStart
+
+		// Kvota tugagani uchun to'xtatilgan clientni qayta yoqish
+		if !client.Active && client.SuspendReason == models.SuspendReasonQuota {
+			if err := database.ResumeClient(client, backend); err != nil {
+				log.Printf("Kvota: client %d ni qayta yoqishda xatolik: %v", client.ID, err)
+			} else {
+				log.Printf("Kvota: yangi davr boshlandi, client %d qayta yoqildi", client.ID)
+			}
+		}
+
+		database.RecordAudit(database.ActorQuota, "", database.AuditQuotaReset, client.ID, &previous, client)
+	}
+}
+
+// enforceQuotas - Kvotasi tugagan clientlarga siyosatni qo'llash
+func enforceQuotas(backend wireguard.Backend) {
+	clients, err := database.GetClientsOverQuota()
+	if err != nil {
+		log.Printf("Kvota: clientlarni olishda xatolik: %v", err)
+		return
+	}
+
+	for i := range clients {
+		client := &clients[i]
+		previous := *client
+
+		// Hodisa faqat siyosat qo'llangandan keyin yuboriladi: aks holda xatolik takrorlanganda
+		// har bir o'qishda yangi hodisa va webhook yetkazmasi yaratiladi
+		switch config.Config.Quota.Policy {
+		case QuotaPolicyDelete:
+			if err := database.DeprovisionClient(client, events.ClientDeleted, backend); err != nil {
+				log.Printf("Kvota: client %d ni o'chirishda xatolik: %v", client.ID, err)
+				continue
+			}
+			events.PublishClient(events.ClientQuotaExceeded, client)
+			database.RecordAudit(database.ActorQuota, "", database.AuditQuotaEnforce, client.ID, &previous, nil)
+			log.Printf("Kvota: client %d kvotasi tugadi va o'chirildi", client.ID)
+		default:
+			if err := database.SuspendClient(client, models.SuspendReasonQuota, backend); err != nil {
+				log.Printf("Kvota: client %d ni to'xtatishda xatolik: %v", client.ID, err)
+				continue
+			}
+			events.PublishClient(events.ClientQuotaExceeded, client)
+			database.RecordAudit(database.ActorQuota, "", database.AuditQuotaEnforce, client.ID, &previous, client)
+			log.Printf("Kvota: client %d kvotasi tugadi va to'xtatildi", client.ID)
+		}
+	}
+}
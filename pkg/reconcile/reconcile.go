@@ -0,0 +1,311 @@
+package reconcile
+
+import (
+	"fmt"
+	"log"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"wireguard-vpn-client-creater/pkg/database"
+	"wireguard-vpn-client-creater/pkg/models"
+	"wireguard-vpn-client-creater/pkg/wireguard"
+)
+
+// OrphanPeer - Interfaceda bor, lekin databaseda yo'q peer
+type OrphanPeer struct {
+	PublicKey  string   `json:"public_key"`
+	AllowedIPs []string `json:"allowed_ips"`
+}
+
+// MissingPeer - Databaseda bor, lekin interfaceda yo'q client
+type MissingPeer struct {
+	ClientID    uint     `json:"client_id"`
+	Description string   `json:"description"`
+	PublicKey   string   `json:"public_key"`
+	AllowedIPs  []string `json:"allowed_ips"`
+}
+
+// SuspendedPeer - To'xtatilgan, lekin interfaceda hali ham mavjud client peeri
+type SuspendedPeer struct {
+	ClientID  uint   `json:"client_id"`
+	PublicKey string `json:"public_key"`
+}
+
+// MismatchedPeer - Databasedagi va interfacedagi sozlamalari farq qiladigan peer
+type MismatchedPeer struct {
+	ClientID             uint     `json:"client_id"`
+	PublicKey            string   `json:"public_key"`
+	ExpectedAllowedIPs   []string `json:"expected_allowed_ips"`
+	ActualAllowedIPs     []string `json:"actual_allowed_ips"`
+	AllowedIPsMismatch   bool     `json:"allowed_ips_mismatch"`
+	PresharedKeyMismatch bool     `json:"preshared_key_mismatch"`
+}
+
+// Report - Database va interface o'rtasidagi farqlar hisoboti
+type Report struct {
+	CheckedAt    time.Time        `json:"checked_at"`
+	InSync       bool             `json:"in_sync"`
+	OrphanPeers  []OrphanPeer     `json:"orphan_peers"`
+	MissingPeers []MissingPeer    `json:"missing_peers"`
+	Mismatched   []MismatchedPeer `json:"mismatched"`
+	Suspended    []SuspendedPeer  `json:"suspended_peers"`
+	Repaired     bool             `json:"repaired"`
+	RepairErrors []string         `json:"repair_errors,omitempty"`
+
+	// Create/delete/update amallarida qaytarib bo'lmagan o'zgarishlar
+	PendingRollbacks []models.RollbackFailure `json:"pending_rollbacks"`
+}
+
+var (
+	lastReport *Report
+	mu         sync.Mutex // Bir vaqtda faqat bitta tekshiruv ishlashi uchun
+)
+
+// Check - Database va interfacedagi peerlarni solishtirish.
+// Tekshiruv davomida client yaratish/o'chirish/o'zgartirish kutib turadi, shuning uchun yarim bajarilgan amal farq sifatida ko'rinmaydi.
+func Check(backend wireguard.Backend) (*Report, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	database.LockPeers()
+	defer database.UnlockPeers()
+
+	return check(backend)
+}
+
+// Run - Farqlarni tekshirish va autoRepair yoqilgan bo'lsa, ularni tuzatish
+func Run(backend wireguard.Backend, autoRepair bool) (*Report, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	database.LockPeers()
+	defer database.UnlockPeers()
+
+	report, err := check(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	if autoRepair && !report.InSync {
+		repair(backend, report)
+	}
+
+	// Holat mos bo'lsa yoki muvaffaqiyatli tuzatilgan bo'lsa, ochiq rollback xatoliklari ham yopiladi
+	if len(report.PendingRollbacks) > 0 && (report.InSync || (report.Repaired && len(report.RepairErrors) == 0)) {
+		if err := database.ResolveRollbackFailures(); err != nil {
+			log.Printf("Rollback xatoliklarini yopishda xatolik: %v", err)
+		}
+	}
+
+	lastReport = report
+	return report, nil
+}
+
+// LastReport - Oxirgi rejalashtirilgan tekshiruv natijasini olish
+func LastReport() *Report {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return lastReport
+}
+
+// check - Farqlarni aniqlash (mu qulflangan bo'lishi kerak)
+func check(backend wireguard.Backend) (*Report, error) {
+	clients, err := database.GetAllClients()
+	if err != nil {
+		return nil, fmt.Errorf("clientlarni olishda xatolik: %v", err)
+	}
+
+	peers, err := backend.ListPeers()
+	if err != nil {
+		return nil, fmt.Errorf("interfacedagi peerlarni olishda xatolik: %v", err)
+	}
+
+	// Peerlarni public key bo'yicha map ga o'tkazish
+	peerMap := make(map[string]wireguard.PeerStats, len(peers))
+	for _, peer := range peers {
+		peerMap[peer.PublicKey] = peer
+	}
+
+	report := &Report{
+		CheckedAt:    time.Now(),
+		OrphanPeers:  []OrphanPeer{},
+		MissingPeers: []MissingPeer{},
+		Mismatched:   []MismatchedPeer{},
+		Suspended:    []SuspendedPeer{},
+	}
+
+	clientKeys := make(map[string]bool, len(clients))
+	for i := range clients {
+		client := &clients[i]
+		clientKeys[client.PublicKey] = true
+		expected := wireguard.PeerFromClient(client)
+
+		peer, exists := peerMap[client.PublicKey]
+
+		// To'xtatilgan clientning peeri interfaceda bo'lmasligi kerak
+		if !client.Active {
+			if exists {
+				report.Suspended = append(report.Suspended, SuspendedPeer{
+					ClientID:  client.ID,
+					PublicKey: client.PublicKey,
+				})
+			}
+			continue
+		}
+
+		if !exists {
+			report.MissingPeers = append(report.MissingPeers, MissingPeer{
+				ClientID:    client.ID,
+				Description: client.Description,
+				PublicKey:   client.PublicKey,
+				AllowedIPs:  expected.AllowedIPs,
+			})
+			continue
+		}
+
+		allowedIPsMismatch := !sameAllowedIPs(expected.AllowedIPs, peer.AllowedIPs)
+		presharedKeyMismatch := expected.PresharedKey != peer.PresharedKey
+		if allowedIPsMismatch || presharedKeyMismatch {
+			report.Mismatched = append(report.Mismatched, MismatchedPeer{
+				ClientID:             client.ID,
+				PublicKey:            client.PublicKey,
+				ExpectedAllowedIPs:   expected.AllowedIPs,
+				ActualAllowedIPs:     peer.AllowedIPs,
+				AllowedIPsMismatch:   allowedIPsMismatch,
+				PresharedKeyMismatch: presharedKeyMismatch,
+			})
+		}
+	}
+
+	// Databaseda yo'q peerlarni topish
+	for _, peer := range peers {
+		if !clientKeys[peer.PublicKey] {
+			report.OrphanPeers = append(report.OrphanPeers, OrphanPeer{
+				PublicKey:  peer.PublicKey,
+				AllowedIPs: peer.AllowedIPs,
+			})
+		}
+	}
+
+	// Qaytarib bo'lmagan o'zgarishlar ro'yxati
+	report.PendingRollbacks, err = database.GetUnresolvedRollbackFailures()
+	if err != nil {
+		return nil, fmt.Errorf("rollback xatoliklarini olishda xatolik: %v", err)
+	}
+
+	report.InSync = len(report.OrphanPeers) == 0 && len(report.MissingPeers) == 0 &&
+		len(report.Mismatched) == 0 && len(report.Suspended) == 0
+	return report, nil
+}
+
+// repair - Database holatini interfacega qo'llash (database asosiy manba hisoblanadi)
+func repair(backend wireguard.Backend, report *Report) {
+	report.Repaired = true
+
+	// Databaseda yo'q peerlarni interfacedan o'chirish
+	for _, orphan := range report.OrphanPeers {
+		if err := backend.RemovePeer(orphan.PublicKey); err != nil {
+			report.RepairErrors = append(report.RepairErrors, fmt.Sprintf("orphan peer %s: %v", orphan.PublicKey, err))
+			continue
+		}
+		log.Printf("Drift: orphan peer interfacedan o'chirildi: %s", orphan.PublicKey)
+	}
+
+	// To'xtatilgan clientlarning peerlarini olib tashlash
+	for _, suspended := range report.Suspended {
+		if err := backend.RemovePeer(suspended.PublicKey); err != nil {
+			report.RepairErrors = append(report.RepairErrors, fmt.Sprintf("client %d: %v", suspended.ClientID, err))
+			continue
+		}
+		log.Printf("Drift: to'xtatilgan client %d peeri interfacedan olib tashlandi", suspended.ClientID)
+	}
+
+	// Yetishmayotgan va farq qiladigan peerlarni databasedagi holatga keltirish
+	_, restoreErrors := restorePeers(backend, report)
+	report.RepairErrors = append(report.RepairErrors, restoreErrors...)
+}
+
+// Restore - Databasedagi faol clientlarning yetishmayotgan yoki farq qiladigan peerlarini interfacega qo'shish.
+// Server ishga tushganda ishlatiladi (netlink backend o'zgarishlarni wg0.conf fayliga yozmaydi).
+// Orphan va to'xtatilgan peerlarga tegilmaydi, ularni faqat auto_repair olib tashlaydi.
+func Restore(backend wireguard.Backend) (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	database.LockPeers()
+	defer database.UnlockPeers()
+
+	report, err := check(backend)
+	if err != nil {
+		return 0, err
+	}
+
+	restored, restoreErrors := restorePeers(backend, report)
+	for _, restoreErr := range restoreErrors {
+		log.Printf("Peerlarni tiklashda xatolik: %s", restoreErr)
+	}
+	return restored, nil
+}
+
+// restorePeers - Hisobotdagi yetishmayotgan va farq qiladigan peerlarni databasedagi holatga keltirish
+func restorePeers(backend wireguard.Backend, report *Report) (int, []string) {
+	var clientIDs []uint
+	for _, missing := range report.MissingPeers {
+		clientIDs = append(clientIDs, missing.ClientID)
+	}
+	for _, mismatched := range report.Mismatched {
+		clientIDs = append(clientIDs, mismatched.ClientID)
+	}
+
+	restored := 0
+	var failures []string
+	for _, id := range clientIDs {
+		client, err := database.GetClientByID(id)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("client %d: %v", id, err))
+			continue
+		}
+
+		if err := backend.AddPeer(wireguard.PeerFromClient(&client)); err != nil {
+			failures = append(failures, fmt.Sprintf("client %d: %v", id, err))
+			continue
+		}
+		restored++
+		log.Printf("Drift: client %d peeri interfacede tiklandi", client.ID)
+	}
+
+	return restored, failures
+}
+
+// sameAllowedIPs - Ikki allowed-ips ro'yxati tartibidan qat'i nazar bir xilligini tekshirish
+func sameAllowedIPs(expected, actual []string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+
+	a := normalizeAllowedIPs(expected)
+	b := normalizeAllowedIPs(actual)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalizeAllowedIPs - CIDR larni kanonik ko'rinishga keltirib saralash
+func normalizeAllowedIPs(allowedIPs []string) []string {
+	normalized := make([]string, 0, len(allowedIPs))
+	for _, allowedIP := range allowedIPs {
+		allowedIP = strings.TrimSpace(allowedIP)
+		if prefix, err := netip.ParsePrefix(allowedIP); err == nil {
+			allowedIP = prefix.Masked().String()
+		}
+		normalized = append(normalized, allowedIP)
+	}
+
+	sort.Strings(normalized)
+	return normalized
+}
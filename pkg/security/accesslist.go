@@ -0,0 +1,77 @@
+package security
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Ruxsat va taqiq ro'yxatlari nomlari
+const (
+	AccessListAllow = "allow" // Hech qachon bloklanmaydi
+	AccessListDeny  = "deny"  // Har doim bloklangan
+)
+
+// ParseCIDR - CIDR yoki oddiy IP manzilni (/32 yoki /128 sifatida) tarmoqqa o'tkazish
+func ParseCIDR(value string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("noto'g'ri IP manzil yoki CIDR: %s", value)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("noto'g'ri IP manzil yoki CIDR: %s", value)
+	}
+	return network, nil
+}
+
+// ParseCIDRs - CIDR ro'yxatini tarmoqlarga o'tkazish
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		network, err := ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// containsIP - IP manzil tarmoqlardan biriga tegishlimi
+func containsIP(networks []*net.IPNet, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlapsAny - CIDR (yoki IP) tarmoqlardan biri bilan kesishadimi
+func overlapsAny(networks []*net.IPNet, cidr string) bool {
+	target, err := ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(target.IP) || target.Contains(network.IP) {
+			return true
+		}
+	}
+	return false
+}
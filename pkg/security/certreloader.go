@@ -0,0 +1,132 @@
+package security
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader - API sertifikatini (va mutual TLS uchun mijozlar CA sini) fayllar o'zgarganda qayta yuklovchi
+type CertReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mu       sync.RWMutex
+	config   *tls.Config
+	modTimes map[string]time.Time // Oxirgi muvaffaqiyatli yuklangan fayllar vaqti
+}
+
+// NewCertReloader - Sertifikatlarni yuklab, qayta yuklovchini yaratish
+func NewCertReloader(certFile, keyFile, clientCAFile string) (*CertReloader, error) {
+	reloader := &CertReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+	}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// TLSConfig - Server uchun TLS konfiguratsiyasi. Har bir yangi ulanish joriy sertifikatdan foydalanadi.
+func (r *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.config, nil
+		},
+	}
+}
+
+// Watch - Fayllar o'zgarishini davriy tekshirish. Yangi fayllar yaroqsiz bo'lsa oldingi sertifikat ishlatilaveradi.
+func (r *CertReloader) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if !r.changed() {
+				continue
+			}
+			if err := r.reload(); err != nil {
+				log.Printf("TLS sertifikatini qayta yuklashda xatolik: %v", err)
+				continue
+			}
+			log.Println("TLS sertifikati qayta yuklandi")
+		}
+	}()
+}
+
+// files - Kuzatiladigan fayllar ro'yxati
+func (r *CertReloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.clientCAFile != "" {
+		files = append(files, r.clientCAFile)
+	}
+	return files
+}
+
+// changed - Oxirgi yuklashdan keyin birorta fayl o'zgarganmi
+func (r *CertReloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			// Fayl almashtirilayotgan bo'lishi mumkin, keyingi tekshiruvda qayta uriniladi
+			continue
+		}
+		if !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// reload - Sertifikat, kalit va CA fayllarini o'qib, yangi TLS konfiguratsiyasini yaratish
+func (r *CertReloader) reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("faylni o'qishda xatolik: %v", err)
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("sertifikat yoki kalitni o'qishda xatolik: %v", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if r.clientCAFile != "" {
+		data, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("mijozlar CA faylini o'qishda xatolik: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("mijozlar CA faylida PEM sertifikat topilmadi: %s", r.clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r.mu.Lock()
+	r.config = config
+	r.modTimes = modTimes
+	r.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,200 @@
+package security
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// firewallCommandTimeout - Bitta nft/ipset/iptables buyrug'i uchun maksimal vaqt
+const firewallCommandTimeout = 5 * time.Second
+
+// Firewall - Bloklangan IP manzillarni tarmoq darajasida to'xtatish (HTTP 429 o'rniga paketlar tashlab yuboriladi)
+type Firewall interface {
+	// Block - IP manzil yoki subnetni (CIDR) timeout muddatga bloklash (0 = muddatsiz).
+	// Muddat tugaganda yozuv firewallning o'zida o'chadi.
+	Block(ip string, timeout time.Duration) error
+	// Unblock - IP manzil yoki subnetni blokdan chiqarish
+	Unblock(ip string) error
+}
+
+// NewFirewall - Konfiguratsiyadagi backend bo'yicha firewall yaratish va set/qoidalarni tayyorlash.
+// backend bo'sh bo'lsa nil qaytariladi (faqat HTTP darajasida bloklash).
+func NewFirewall(backend, table, set string, port int) (Firewall, error) {
+	var firewall interface {
+		Firewall
+		setup() error
+	}
+
+	switch backend {
+	case "":
+		return nil, nil
+	case "nftables":
+		firewall = &NftablesFirewall{Table: table, Set: set, Port: port}
+	case "ipset":
+		firewall = &IPSetFirewall{Set: set, Port: port}
+	default:
+		return nil, fmt.Errorf("noma'lum firewall backend: %s (nftables yoki ipset bo'lishi kerak)", backend)
+	}
+
+	if err := firewall.setup(); err != nil {
+		return nil, err
+	}
+	return firewall, nil
+}
+
+// NftablesFirewall - Alohida nftables jadvali: IPv4/IPv6 setlari va API portiga kelgan paketlarni tashlash qoidasi
+type NftablesFirewall struct {
+	Table string // inet oilasidagi jadval nomi (faqat shu dastur boshqaradi)
+	Set   string // Set nomi; IPv6 uchun "_v6" qo'shimchasi bilan
+	Port  int    // API porti
+}
+
+// setup - Jadvalni qayta yaratish (dastur ishga tushganda bloklar databasedan qayta qo'shiladi)
+func (f *NftablesFirewall) setup() error {
+	script := fmt.Sprintf(`table inet %[1]s {}
+delete table inet %[1]s
+table inet %[1]s {
+	set %[2]s { type ipv4_addr; flags interval, timeout; }
+	set %[2]s_v6 { type ipv6_addr; flags interval, timeout; }
+	chain input {
+		type filter hook input priority -10; policy accept;
+		ip saddr @%[2]s tcp dport %[3]d drop
+		ip6 saddr @%[2]s_v6 tcp dport %[3]d drop
+	}
+}
+`, f.Table, f.Set, f.Port)
+
+	return runFirewallCommand(strings.NewReader(script), "nft", "-f", "-")
+}
+
+// Block - IP manzil yoki subnetni nftables setiga qo'shish
+func (f *NftablesFirewall) Block(ip string, timeout time.Duration) error {
+	set, err := f.setFor(ip)
+	if err != nil {
+		return err
+	}
+
+	element := ip
+	if timeout > 0 {
+		element += " timeout " + strconv.Itoa(timeoutSeconds(timeout)) + "s"
+	}
+
+	// Element allaqachon bo'lsa uni yangi muddat bilan almashtirish
+	runFirewallCommand(nil, "nft", "delete", "element", "inet", f.Table, set, "{ "+ip+" }")
+	return runFirewallCommand(nil, "nft", "add", "element", "inet", f.Table, set, "{ "+element+" }")
+}
+
+// Unblock - IP manzil yoki subnetni nftables setidan olib tashlash
+func (f *NftablesFirewall) Unblock(ip string) error {
+	set, err := f.setFor(ip)
+	if err != nil {
+		return err
+	}
+	return runFirewallCommand(nil, "nft", "delete", "element", "inet", f.Table, set, "{ "+ip+" }")
+}
+
+// setFor - IP manzil yoki subnet versiyasiga mos set nomi
+func (f *NftablesFirewall) setFor(ip string) (string, error) {
+	return familySet(f.Set, ip)
+}
+
+// IPSetFirewall - ipset setlari va iptables/ip6tables qoidalari orqali bloklash
+type IPSetFirewall struct {
+	Set  string // Set nomi; IPv6 uchun "_v6" qo'shimchasi bilan
+	Port int    // API porti
+}
+
+// setup - Setlarni (hash:net, subnetlar uchun ham) va API portini bloklovchi qoidalarni (mavjud bo'lmasa) yaratish
+func (f *IPSetFirewall) setup() error {
+	families := []struct {
+		set      string
+		family   string
+		iptables string
+	}{
+		{f.Set, "inet", "iptables"},
+		{f.Set + "_v6", "inet6", "ip6tables"},
+	}
+
+	for _, family := range families {
+		if err := runFirewallCommand(nil, "ipset", "create", family.set, "hash:net", "family", family.family, "timeout", "0", "-exist"); err != nil {
+			return err
+		}
+
+		rule := []string{"INPUT", "-p", "tcp", "--dport", strconv.Itoa(f.Port), "-m", "set", "--match-set", family.set, "src", "-j", "DROP"}
+		if runFirewallCommand(nil, family.iptables, append([]string{"-C"}, rule...)...) == nil {
+			continue
+		}
+		if err := runFirewallCommand(nil, family.iptables, append([]string{"-I"}, rule...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Block - IP manzil yoki subnetni ipset ga qo'shish (mavjud bo'lsa muddati yangilanadi)
+func (f *IPSetFirewall) Block(ip string, timeout time.Duration) error {
+	set, err := f.setFor(ip)
+	if err != nil {
+		return err
+	}
+	seconds := strconv.Itoa(timeoutSeconds(timeout))
+	return runFirewallCommand(nil, "ipset", "add", set, ip, "timeout", seconds, "-exist")
+}
+
+// Unblock - IP manzil yoki subnetni ipset dan olib tashlash
+func (f *IPSetFirewall) Unblock(ip string) error {
+	set, err := f.setFor(ip)
+	if err != nil {
+		return err
+	}
+	return runFirewallCommand(nil, "ipset", "del", set, ip, "-exist")
+}
+
+// setFor - IP manzil yoki subnet versiyasiga mos set nomi
+func (f *IPSetFirewall) setFor(ip string) (string, error) {
+	return familySet(f.Set, ip)
+}
+
+// timeoutSeconds - Firewall yozuvi muddati sekundlarda (0 = muddatsiz).
+// Yuqoriga yaxlitlanadi: 0.5 sekunddan kam qolgan muddat 0 ga aylansa ipset uni muddatsiz deb qabul qiladi.
+func timeoutSeconds(timeout time.Duration) int {
+	if timeout <= 0 {
+		return 0
+	}
+	return int((timeout + time.Second - 1) / time.Second)
+}
+
+// familySet - IPv4 uchun set nomi, IPv6 uchun "_v6" qo'shimchasi bilan
+func familySet(set, ip string) (string, error) {
+	network, err := ParseCIDR(ip)
+	if err != nil {
+		return "", err
+	}
+	if network.IP.To4() != nil {
+		return set, nil
+	}
+	return set + "_v6", nil
+}
+
+// runFirewallCommand - Firewall buyrug'ini timeout bilan bajarish
+func runFirewallCommand(stdin *strings.Reader, name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), firewallCommandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s buyrug'i %s ichida tugamadi", name, firewallCommandTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s: %v (%s)", name, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package security
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeoutSeconds(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		want    int
+	}{
+		{"muddatsiz", 0, 0},
+		{"manfiy", -time.Second, 0},
+		{"yarim sekunddan kam", 300 * time.Millisecond, 1},
+		{"butun sekund", 5 * time.Second, 5},
+		{"yuqoriga yaxlitlanadi", 5*time.Second + time.Millisecond, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timeoutSeconds(tt.timeout); got != tt.want {
+				t.Errorf("timeoutSeconds(%s) = %d, kutilgan %d", tt.timeout, got, tt.want)
+			}
+		})
+	}
+}
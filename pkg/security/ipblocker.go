@@ -3,23 +3,121 @@ package security
 import (
 	"fmt"
 	"log"
+	"net"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
+// Block - Bitta bloklangan IP manzil yoki subnet
+type Block struct {
+	IP        string // IP manzil yoki subnet (CIDR)
+	BlockedAt time.Time
+	ExpiresAt time.Time // Nol qiymat - muddatsiz blok
+	Reason    string
+	Offense   int // Nechanchi marta bloklanishi (blok muddati shunga qarab oshadi)
+}
+
+// Permanent - Blok muddatsizmi
+func (b Block) Permanent() bool {
+	return b.ExpiresAt.IsZero()
+}
+
+// activeAt - Blok berilgan vaqtda amal qiladimi
+func (b Block) activeAt(now time.Time) bool {
+	return b.Permanent() || now.Before(b.ExpiresAt)
+}
+
+// BlockInfo - Blok va shu IP (yoki subnet) dan oxirgi oynada kelgan muvaffaqiyatsiz urinishlar soni
+type BlockInfo struct {
+	Block
+	Attempts int
+}
+
+// BlockStore - Bloklarni dastur qayta ishga tushganda ham saqlab qolish uchun ombor (masalan database)
+type BlockStore interface {
+	// LoadBlocks - Barcha saqlangan bloklarni olish (takroriy bloklarni hisoblash uchun muddati o'tganlari ham)
+	LoadBlocks() ([]Block, error)
+	// SaveBlock - Blokni saqlash (shu IP uchun mavjud blok almashtiriladi)
+	SaveBlock(block Block) error
+	// DeleteBlock - IP manzil blokini o'chirish
+	DeleteBlock(ip string) error
+}
+
+// Blok sabablari
+const (
+	BlockReasonFailedAuth = "failed_auth" // Ko'p marta xato token yoki parol
+	BlockReasonSubnet     = "subnet"      // Bitta subnetdan ko'p xato urinishlar
+	BlockReasonManual     = "manual"      // Administrator tomonidan qo'lda
+	BlockReasonDenylist   = "denylist"    // Doimiy taqiq ro'yxati
+)
+
+// BlockPolicy - Qachon va qancha muddatga bloklash siyosati
+type BlockPolicy struct {
+	MaxAttempts  int             // Bitta IP uchun oynadagi maksimal xato urinishlar
+	Window       time.Duration   // Urinishlar hisoblanadigan oyna (0 = urinishlar eskirmaydi)
+	BanDurations []time.Duration // Ketma-ket bloklar muddati, oxirgisi keyingi barcha bloklarga (0 = muddatsiz)
+	OffenseTTL   time.Duration   // Shu vaqt ichida qayta bloklanmasa muddat boshidan hisoblanadi (0 = unutilmaydi)
+
+	SubnetMaxAttempts int // Bitta subnet uchun oynadagi maksimal xato urinishlar (0 = o'chirilgan)
+	IPv4Prefix        int // IPv4 subnet uzunligi (masalan 24)
+	IPv6Prefix        int // IPv6 subnet uzunligi (masalan 64)
+}
+
+// prefixLength - Subnet bloki prefiksi (manzil oilasi va uzunligi)
+type prefixLength struct {
+	ones, bits int
+}
+
+// offense - IP yoki subnetning oldingi bloklari
+type offense struct {
+	count int
+	last  time.Time
+}
+
 // IPBlocker - IP manzillarni bloklash uchun struktura
 type IPBlocker struct {
-	failedAttempts map[string]int       // IP -> urinishlar soni
-	blockedIPs     map[string]time.Time // IP -> bloklash vaqti
-	blockDuration  time.Duration        // Bloklash muddati
-	maxAttempts    int                  // Maksimal urinishlar soni
-	mu             sync.RWMutex         // Thread-safe qilish uchun mutex
-	logFile        *os.File             // Log fayli
+	failedAttempts map[string][]time.Time // IP -> oynadagi xato urinishlar vaqti
+	subnetAttempts map[string][]time.Time // Subnet -> oynadagi xato urinishlar vaqti
+	blockedIPs     map[string]Block       // IP yoki subnet -> blok
+	subnetIndex    map[prefixLength]int   // Bloklangan subnetlar prefiks uzunliklari -> bloklar soni (Lookup uchun)
+	offenses       map[string]offense     // IP yoki subnet -> oldingi bloklar
+	policy         BlockPolicy            // Bloklash siyosati
+	mu             sync.RWMutex           // Thread-safe qilish uchun mutex
+	logFile        *os.File               // Log fayli
+	store          BlockStore             // Bloklar ombori (ixtiyoriy)
+	firewall       Firewall               // Tarmoq darajasida bloklash (ixtiyoriy)
+	firewallMu     sync.Mutex             // Firewall o'zgarishlarini ketma-ket bajarish uchun
+	allowlist      []*net.IPNet           // Hech qachon bloklanmaydigan tarmoqlar
+	denylist       []*net.IPNet           // Har doim bloklangan tarmoqlar
+
+	// OnBlock - IP manzil yoki subnet bloklanganda chaqiriladi (ixtiyoriy)
+	OnBlock func(ip string, until time.Time)
 }
 
-// NewIPBlocker - Yangi IPBlocker yaratish
-func NewIPBlocker(blockDuration time.Duration, maxAttempts int, logFilePath string) (*IPBlocker, error) {
+// NewIPBlocker - Yangi IPBlocker yaratish. store berilgan bo'lsa oldingi bloklar va bloklar tarixi tiklanadi,
+// firewall berilgan bo'lsa amaldagi bloklar unga qolgan muddat bilan qayta qo'shiladi.
+func NewIPBlocker(policy BlockPolicy, logFilePath string, store BlockStore, firewall Firewall) (*IPBlocker, error) {
+	if policy.MaxAttempts <= 0 {
+		return nil, fmt.Errorf("maksimal urinishlar soni musbat bo'lishi kerak")
+	}
+	if len(policy.BanDurations) == 0 {
+		return nil, fmt.Errorf("kamida bitta bloklash muddati ko'rsatilishi kerak")
+	}
+	for _, duration := range policy.BanDurations {
+		if duration < 0 {
+			return nil, fmt.Errorf("bloklash muddati manfiy bo'lishi mumkin emas")
+		}
+	}
+	if policy.IPv4Prefix <= 0 || policy.IPv4Prefix > 32 {
+		policy.IPv4Prefix = 24
+	}
+	if policy.IPv6Prefix <= 0 || policy.IPv6Prefix > 128 {
+		policy.IPv6Prefix = 64
+	}
+
 	// Log faylini ochish
 	logFile, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -28,11 +126,46 @@ func NewIPBlocker(blockDuration time.Duration, maxAttempts int, logFilePath stri
 
 	// IPBlocker yaratish
 	blocker := &IPBlocker{
-		failedAttempts: make(map[string]int),
-		blockedIPs:     make(map[string]time.Time),
-		blockDuration:  blockDuration,
-		maxAttempts:    maxAttempts,
+		failedAttempts: make(map[string][]time.Time),
+		subnetAttempts: make(map[string][]time.Time),
+		blockedIPs:     make(map[string]Block),
+		subnetIndex:    make(map[prefixLength]int),
+		offenses:       make(map[string]offense),
+		policy:         policy,
 		logFile:        logFile,
+		store:          store,
+		firewall:       firewall,
+	}
+
+	// Saqlangan bloklarni tiklash
+	if store != nil {
+		blocks, err := store.LoadBlocks()
+		if err != nil {
+			logFile.Close()
+			return nil, fmt.Errorf("saqlangan bloklarni o'qishda xatolik: %v", err)
+		}
+
+		now := time.Now()
+		for _, block := range blocks {
+			active := block.activeAt(now)
+			forgotten := policy.OffenseTTL > 0 && now.Sub(block.BlockedAt) > policy.OffenseTTL
+			if !active && (block.Offense == 0 || forgotten) {
+				// Dastur ishlamay turganda eskirgan blok
+				blocker.deleteBlock(block.IP)
+				continue
+			}
+
+			if block.Offense > 0 {
+				blocker.offenses[block.IP] = offense{count: block.Offense, last: block.BlockedAt}
+			}
+			if active {
+				blocker.setBlock(block)
+				blocker.firewallBlock(block)
+			}
+		}
+		if len(blocker.blockedIPs) > 0 {
+			log.Printf("IP bloklash: %d ta saqlangan blok tiklandi", len(blocker.blockedIPs))
+		}
 	}
 
 	// Eskirgan bloklarni tozalash uchun goroutine ishga tushirish
@@ -41,56 +174,233 @@ func NewIPBlocker(blockDuration time.Duration, maxAttempts int, logFilePath stri
 	return blocker, nil
 }
 
+// SetAccessLists - Ruxsat va taqiq ro'yxatlarini almashtirish. Ruxsat ro'yxati taqiq ro'yxatidan ustun.
+func (b *IPBlocker) SetAccessLists(allowlist, denylist []*net.IPNet) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.allowlist = allowlist
+	b.denylist = denylist
+}
+
+// IsAllowed - IP manzil ruxsat ro'yxatidami
+func (b *IPBlocker) IsAllowed(ip string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return containsIP(b.allowlist, ip)
+}
+
 // IsBlocked - IP manzil bloklangan yoki yo'qligini tekshirish
 func (b *IPBlocker) IsBlocked(ip string) bool {
+	_, blocked := b.Lookup(ip)
+	return blocked
+}
+
+// Lookup - IP manzilga amal qilayotgan blokni olish: manzilning o'z bloki, uni qamrab oluvchi
+// subnet bloki yoki taqiq ro'yxati (muddatsiz blok sifatida)
+func (b *IPBlocker) Lookup(ip string) (Block, bool) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	// IP manzil bloklangan vaqtini olish
-	blockTime, exists := b.blockedIPs[ip]
-	if !exists {
-		return false
+	if containsIP(b.allowlist, ip) {
+		return Block{}, false
+	}
+	if containsIP(b.denylist, ip) {
+		return Block{IP: ip, Reason: BlockReasonDenylist}, true
 	}
 
-	// Bloklash muddati tugagan bo'lsa, blokni olib tashlash
-	if time.Since(blockTime) > b.blockDuration {
-		delete(b.blockedIPs, ip)
-		delete(b.failedAttempts, ip)
-		return false
+	// Muddati tugagan bloklar cleanupExpiredBlocks tomonidan o'chiriladi
+	now := time.Now()
+	if block, exists := b.blockedIPs[ip]; exists && block.activeAt(now) {
+		return block, true
 	}
 
-	return true
+	// Faqat bloklangan subnetlar uchrashadigan prefiks uzunliklari bo'yicha tekshiriladi
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return Block{}, false
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		parsed = v4
+	}
+	for prefix := range b.subnetIndex {
+		if prefix.bits != len(parsed)*8 {
+			continue
+		}
+		mask := net.CIDRMask(prefix.ones, prefix.bits)
+		key := NetworkKey(&net.IPNet{IP: parsed.Mask(mask), Mask: mask})
+		if block, exists := b.blockedIPs[key]; exists && block.activeAt(now) {
+			return block, true
+		}
+	}
+	return Block{}, false
 }
 
 // RecordFailedAttempt - Muvaffaqiyatsiz urinishni qayd qilish
 func (b *IPBlocker) RecordFailedAttempt(ip string, userAgent string, requestPath string) {
 	b.mu.Lock()
-	defer b.mu.Unlock()
 
-	// IP manzil uchun urinishlar sonini oshirish
-	b.failedAttempts[ip]++
+	// Ruxsat ro'yxatidagi manzillar hisobga olinmaydi
+	if containsIP(b.allowlist, ip) {
+		b.mu.Unlock()
+		return
+	}
+
+	// Oynadan tashqaridagi eski urinishlar hisobga olinmaydi
+	now := time.Now()
+	b.failedAttempts[ip] = append(b.pruneAttempts(b.failedAttempts[ip], now), now)
+	attempts := len(b.failedAttempts[ip])
 
 	// Log faylga yozish
-	logEntry := fmt.Sprintf("[%s] Xato token urinishi: IP=%s, User-Agent=%s, Path=%s, Urinishlar=%d/%d\n",
-		time.Now().Format(time.RFC3339), ip, userAgent, requestPath, b.failedAttempts[ip], b.maxAttempts)
-	if _, err := b.logFile.WriteString(logEntry); err != nil {
-		log.Printf("Log faylga yozishda xatolik: %v", err)
+	b.writeLog(fmt.Sprintf("[%s] Xato token urinishi: IP=%s, User-Agent=%s, Path=%s, Urinishlar=%d/%d\n",
+		now.Format(time.RFC3339), ip, userAgent, requestPath, attempts, b.policy.MaxAttempts))
+
+	var blocks []Block
+
+	// Agar urinishlar soni maksimal qiymatga yetsa, IP manzilni bloklash
+	if attempts >= b.policy.MaxAttempts {
+		delete(b.failedAttempts, ip)
+		blocks = append(blocks, b.escalate(ip, BlockReasonFailedAuth, now))
+	}
+
+	// Bitta subnetdagi turli manzillardan kelgan urinishlar birga hisoblanadi
+	if b.policy.SubnetMaxAttempts > 0 {
+		subnet := b.subnetKey(ip)
+		b.subnetAttempts[subnet] = append(b.pruneAttempts(b.subnetAttempts[subnet], now), now)
+		if len(b.subnetAttempts[subnet]) >= b.policy.SubnetMaxAttempts {
+			delete(b.subnetAttempts, subnet)
+			blocks = append(blocks, b.escalate(subnet, BlockReasonSubnet, now))
+		}
+	}
+
+	for _, block := range blocks {
+		b.writeLog(fmt.Sprintf("[%s] IP bloklandi: %s, Sabab: %s, Blok soni: %d, Bloklash muddati: %s\n",
+			now.Format(time.RFC3339), block.IP, block.Reason, block.Offense, blockDurationText(block)))
 	}
+	b.mu.Unlock()
 
-	// Agar urinishlar soni maksimal qiymatdan oshsa, IP manzilni bloklash
-	if b.failedAttempts[ip] >= b.maxAttempts {
-		b.blockedIPs[ip] = time.Now()
+	// Database, firewall va callback mutex ushlab turilgan holda chaqirilmaydi
+	for _, block := range blocks {
+		b.applyBlock(block)
+	}
+}
+
+// escalate - IP yoki subnetni navbatdagi (oldingisidan uzunroq) muddatga bloklash. Mutex ushlab turilgan bo'lishi kerak.
+func (b *IPBlocker) escalate(key, reason string, now time.Time) Block {
+	previous := b.offenses[key]
+	if b.policy.OffenseTTL > 0 && now.Sub(previous.last) > b.policy.OffenseTTL {
+		previous.count = 0
+	}
+
+	count := previous.count + 1
+	b.offenses[key] = offense{count: count, last: now}
+
+	level := count - 1
+	if level >= len(b.policy.BanDurations) {
+		level = len(b.policy.BanDurations) - 1
+	}
+
+	block := Block{IP: key, BlockedAt: now, Reason: reason, Offense: count}
+	if duration := b.policy.BanDurations[level]; duration > 0 {
+		block.ExpiresAt = now.Add(duration)
+	}
+	b.setBlock(block)
+	return block
+}
+
+// BlockIP - IP manzil yoki subnetni (CIDR) qo'lda bloklash. duration 0 bo'lsa blok muddatsiz.
+func (b *IPBlocker) BlockIP(ip string, duration time.Duration, reason string) (Block, error) {
+	network, err := ParseCIDR(ip)
+	if err != nil {
+		return Block{}, err
+	}
+	key := NetworkKey(network)
+
+	now := time.Now()
+	block := Block{IP: key, BlockedAt: now, Reason: reason}
+	if duration > 0 {
+		block.ExpiresAt = now.Add(duration)
+	}
 
-		// Bloklash haqida log yozish
-		blockLogEntry := fmt.Sprintf("[%s] IP bloklandi: %s, Bloklash muddati: %s\n",
-			time.Now().Format(time.RFC3339), ip, b.blockDuration)
-		if _, err := b.logFile.WriteString(blockLogEntry); err != nil {
-			log.Printf("Log faylga yozishda xatolik: %v", err)
+	b.mu.Lock()
+	if !strings.Contains(key, "/") && containsIP(b.allowlist, key) {
+		b.mu.Unlock()
+		return Block{}, fmt.Errorf("%s ruxsat ro'yxatida, uni bloklab bo'lmaydi", key)
+	}
+	block.Offense = b.offenses[key].count
+	b.setBlock(block)
+
+	b.writeLog(fmt.Sprintf("[%s] IP qo'lda bloklandi: %s, Sabab: %s, Bloklash muddati: %s\n",
+		now.Format(time.RFC3339), key, reason, blockDurationText(block)))
+	b.mu.Unlock()
+
+	b.applyBlock(block)
+	return block, nil
+}
+
+// Unblock - IP manzil yoki subnetni blokdan chiqarish, urinishlar va bloklar tarixini o'chirish.
+// Bloklanmagan bo'lsa false qaytariladi (taqiq ro'yxati bu yerda o'zgarmaydi).
+func (b *IPBlocker) Unblock(ip string) bool {
+	network, err := ParseCIDR(ip)
+	if err != nil {
+		return false
+	}
+	key := NetworkKey(network)
+
+	b.mu.Lock()
+	exists := b.removeBlock(key)
+	delete(b.failedAttempts, key)
+	delete(b.subnetAttempts, key)
+	delete(b.offenses, key)
+
+	if exists {
+		b.writeLog(fmt.Sprintf("[%s] IP blokdan chiqarildi: %s\n", time.Now().Format(time.RFC3339), key))
+	}
+	b.mu.Unlock()
+
+	if !exists {
+		return false
+	}
+
+	b.deleteBlock(key)
+	if b.firewall != nil {
+		b.firewallMu.Lock()
+		err := b.firewall.Unblock(key)
+		b.firewallMu.Unlock()
+		if err != nil {
+			log.Printf("IP bloklash: %s ni firewalldan olib tashlashda xatolik: %v", key, err)
+		}
+		b.firewallRestore(key)
+	}
+	return true
+}
+
+// List - Amaldagi barcha bloklar (eng yangisi birinchi)
+func (b *IPBlocker) List() []BlockInfo {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	now := time.Now()
+	blocks := make([]BlockInfo, 0, len(b.blockedIPs))
+	for key, block := range b.blockedIPs {
+		if !block.activeAt(now) {
+			continue
+		}
+		attempts := b.failedAttempts[key]
+		if strings.Contains(key, "/") {
+			attempts = b.subnetAttempts[key]
 		}
+		blocks = append(blocks, BlockInfo{Block: block, Attempts: len(b.pruneAttempts(attempts, now))})
 	}
+
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].BlockedAt.After(blocks[j].BlockedAt)
+	})
+	return blocks
 }
 
-// ResetFailedAttempts - Muvaffaqiyatsiz urinishlar sonini nolga tushirish
+// ResetFailedAttempts - IP manzilning muvaffaqiyatsiz urinishlarini o'chirish (subnet hisobi o'zgarmaydi)
 func (b *IPBlocker) ResetFailedAttempts(ip string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -98,25 +408,38 @@ func (b *IPBlocker) ResetFailedAttempts(ip string) {
 	delete(b.failedAttempts, ip)
 }
 
-// GetRemainingBlockTime - IP manzil uchun qolgan bloklash vaqtini olish
+// GetRemainingBlockTime - IP manzil uchun qolgan bloklash vaqtini olish (muddatsiz blok uchun 0)
 func (b *IPBlocker) GetRemainingBlockTime(ip string) time.Duration {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
-
-	blockTime, exists := b.blockedIPs[ip]
-	if !exists {
+	block, blocked := b.Lookup(ip)
+	if !blocked || block.Permanent() {
 		return 0
 	}
 
-	elapsed := time.Since(blockTime)
-	if elapsed > b.blockDuration {
+	remaining := time.Until(block.ExpiresAt)
+	if remaining < 0 {
 		return 0
 	}
 
-	return b.blockDuration - elapsed
+	return remaining
 }
 
-// cleanupExpiredBlocks - Eskirgan bloklarni tozalash
+// BlockedCount - Hozirda bloklangan IP manzillar va subnetlar soni
+func (b *IPBlocker) BlockedCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	count := 0
+	now := time.Now()
+	for _, block := range b.blockedIPs {
+		if block.activeAt(now) {
+			count++
+		}
+	}
+	return count
+}
+
+// cleanupExpiredBlocks - Eskirgan bloklar, oynadan chiqqan urinishlar va eskirgan bloklar tarixini tozalash.
+// Firewalldagi yozuvlar o'z timeouti bilan o'chadi.
 func (b *IPBlocker) cleanupExpiredBlocks() {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
@@ -125,23 +448,290 @@ func (b *IPBlocker) cleanupExpiredBlocks() {
 		b.mu.Lock()
 		now := time.Now()
 
-		// Eskirgan bloklarni tozalash
-		for ip, blockTime := range b.blockedIPs {
-			if now.Sub(blockTime) > b.blockDuration {
-				delete(b.blockedIPs, ip)
-				delete(b.failedAttempts, ip)
+		// Eskirgan bloklarni tozalash (takroriy blok muddatini hisoblash uchun tarix OffenseTTL gacha saqlanadi)
+		var forgotten, expiredSubnets []string
+		for ip, block := range b.blockedIPs {
+			if !block.activeAt(now) {
+				b.removeBlock(ip)
+				if _, repeat := b.offenses[ip]; !repeat {
+					forgotten = append(forgotten, ip)
+				}
+				if strings.Contains(ip, "/") {
+					expiredSubnets = append(expiredSubnets, ip)
+				}
 
 				// Log yozish
-				logEntry := fmt.Sprintf("[%s] IP bloki olib tashlandi: %s\n",
-					now.Format(time.RFC3339), ip)
-				if _, err := b.logFile.WriteString(logEntry); err != nil {
-					log.Printf("Log faylga yozishda xatolik: %v", err)
+				b.writeLog(fmt.Sprintf("[%s] IP bloki olib tashlandi: %s\n", now.Format(time.RFC3339), ip))
+			}
+		}
+
+		// Oynadan chiqqan urinishlarni tozalash
+		for _, attempts := range []map[string][]time.Time{b.failedAttempts, b.subnetAttempts} {
+			for key, times := range attempts {
+				if pruned := b.pruneAttempts(times, now); len(pruned) == 0 {
+					delete(attempts, key)
+				} else {
+					attempts[key] = pruned
+				}
+			}
+		}
+
+		// Uzoq vaqt qayta bloklanmaganlar tarixini unutish
+		if b.policy.OffenseTTL > 0 {
+			for key, previous := range b.offenses {
+				if _, active := b.blockedIPs[key]; !active && now.Sub(previous.last) > b.policy.OffenseTTL {
+					delete(b.offenses, key)
+					forgotten = append(forgotten, key)
 				}
 			}
 		}
 
 		b.mu.Unlock()
+
+		for _, key := range forgotten {
+			b.deleteBlock(key)
+		}
+		for _, key := range expiredSubnets {
+			b.firewallRestore(key)
+		}
+	}
+}
+
+// pruneAttempts - Oynadan tashqaridagi urinishlarni olib tashlash
+func (b *IPBlocker) pruneAttempts(times []time.Time, now time.Time) []time.Time {
+	if b.policy.Window <= 0 {
+		return times
+	}
+
+	cutoff := now.Add(-b.policy.Window)
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	return times[i:]
+}
+
+// subnetKey - IP manzil tegishli bo'lgan subnet (masalan 203.0.113.0/24)
+func (b *IPBlocker) subnetKey(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		mask := net.CIDRMask(b.policy.IPv4Prefix, 32)
+		return (&net.IPNet{IP: v4.Mask(mask), Mask: mask}).String()
+	}
+	mask := net.CIDRMask(b.policy.IPv6Prefix, 128)
+	return (&net.IPNet{IP: parsed.Mask(mask), Mask: mask}).String()
+}
+
+// NetworkKey - Blok kaliti: bitta manzilli tarmoq uchun IP, aks holda CIDR
+func NetworkKey(network *net.IPNet) string {
+	ones, bits := network.Mask.Size()
+	if ones == bits {
+		return network.IP.String()
+	}
+	return network.String()
+}
+
+// setBlock - Blokni xotiraga qo'shish yoki almashtirish va subnet indeksini yangilash. Mutex ushlab turilgan bo'lishi kerak.
+func (b *IPBlocker) setBlock(block Block) {
+	if _, exists := b.blockedIPs[block.IP]; !exists {
+		if prefix, ok := blockPrefix(block.IP); ok {
+			b.subnetIndex[prefix]++
+		}
+	}
+	b.blockedIPs[block.IP] = block
+}
+
+// removeBlock - Blokni xotiradan o'chirish va subnet indeksini yangilash. Mutex ushlab turilgan bo'lishi kerak.
+func (b *IPBlocker) removeBlock(key string) bool {
+	if _, exists := b.blockedIPs[key]; !exists {
+		return false
+	}
+	delete(b.blockedIPs, key)
+
+	if prefix, ok := blockPrefix(key); ok {
+		if b.subnetIndex[prefix]--; b.subnetIndex[prefix] <= 0 {
+			delete(b.subnetIndex, prefix)
+		}
+	}
+	return true
+}
+
+// blockPrefix - Subnet bloki kalitining prefiksi (bitta manzil uchun false)
+func blockPrefix(key string) (prefixLength, bool) {
+	if !strings.Contains(key, "/") {
+		return prefixLength{}, false
+	}
+	_, network, err := net.ParseCIDR(key)
+	if err != nil {
+		return prefixLength{}, false
+	}
+	ones, bits := network.Mask.Size()
+	return prefixLength{ones: ones, bits: bits}, true
+}
+
+// applyBlock - Yangi blokni saqlash, firewallga qo'shish va OnBlock ni chaqirish
+func (b *IPBlocker) applyBlock(block Block) {
+	b.saveBlock(block)
+	b.firewallBlock(block)
+	if b.OnBlock != nil {
+		go b.OnBlock(block.IP, block.ExpiresAt)
+	}
+}
+
+// saveBlock - Blokni omborga yozish (xatolik faqat logga yoziladi, HTTP darajasidagi blok baribir ishlaydi)
+func (b *IPBlocker) saveBlock(block Block) {
+	if b.store == nil {
+		return
+	}
+	if err := b.store.SaveBlock(block); err != nil {
+		log.Printf("IP bloklash: %s blokini saqlashda xatolik: %v", block.IP, err)
+	}
+}
+
+// deleteBlock - Blokni ombordan o'chirish
+func (b *IPBlocker) deleteBlock(ip string) {
+	if b.store == nil {
+		return
+	}
+	if err := b.store.DeleteBlock(ip); err != nil {
+		log.Printf("IP bloklash: %s blokini o'chirishda xatolik: %v", ip, err)
+	}
+}
+
+// firewallBlock - IP manzil yoki subnetni qolgan muddat bilan firewallga qo'shish.
+// Ruxsat ro'yxati bilan kesishadigan subnetlar firewallga qo'shilmaydi va faqat HTTP darajasida bloklanadi.
+// nftables interval setlari kesishadigan yozuvlarni rad etadi: shuning uchun kengroq blok ichidagi yozuv
+// qo'shilmaydi, yangi subnet qamrab olgan yozuvlar esa undan oldin olib tashlanadi.
+func (b *IPBlocker) firewallBlock(block Block) {
+	if b.firewall == nil {
+		return
+	}
+
+	var timeout time.Duration
+	if !block.Permanent() {
+		timeout = time.Until(block.ExpiresAt)
+		if timeout <= 0 {
+			// Muddati o'tgan blok firewallga muddatsiz bo'lib tushmasligi kerak
+			return
+		}
+	}
+
+	b.firewallMu.Lock()
+	defer b.firewallMu.Unlock()
+
+	now := time.Now()
+	b.mu.RLock()
+	skip := overlapsAny(b.allowlist, block.IP) || b.coveredInFirewall(block.IP, now)
+	var covered []string
+	if !skip {
+		covered = b.firewallBlocksWithin(block.IP, now)
+	}
+	b.mu.RUnlock()
+	if skip {
+		return
+	}
+
+	for _, key := range covered {
+		// Ichki yozuv firewallda bo'lmasligi ham mumkin, shuning uchun xatolik e'tiborsiz qoldiriladi
+		b.firewall.Unblock(key)
+	}
+	if err := b.firewall.Block(block.IP, timeout); err != nil {
+		log.Printf("IP bloklash: %s ni firewallga qo'shishda xatolik: %v", block.IP, err)
+	}
+}
+
+// firewallRestore - Olib tashlangan (yoki muddati tugagan) subnet qamrab turgan amaldagi bloklarni firewallga qaytarish
+func (b *IPBlocker) firewallRestore(key string) {
+	if b.firewall == nil || !strings.Contains(key, "/") {
+		return
+	}
+
+	now := time.Now()
+	b.mu.RLock()
+	var blocks []Block
+	for inner, block := range b.blockedIPs {
+		if block.activeAt(now) && networkCovers(key, inner) {
+			blocks = append(blocks, block)
+		}
+	}
+	b.mu.RUnlock()
+
+	// Kengroq bloklar birinchi: ular ichidagi bloklar firewallBlock da o'tkazib yuboriladi
+	sort.Slice(blocks, func(i, j int) bool {
+		return networkOnes(blocks[i].IP) < networkOnes(blocks[j].IP)
+	})
+	for _, block := range blocks {
+		b.firewallBlock(block)
+	}
+}
+
+// coveredInFirewall - Firewalldagi kengroq amaldagi blok kalitni qamrab oladimi. Mutex ushlab turilgan bo'lishi kerak.
+func (b *IPBlocker) coveredInFirewall(key string, now time.Time) bool {
+	for outer, block := range b.blockedIPs {
+		if block.activeAt(now) && networkCovers(outer, key) && !overlapsAny(b.allowlist, outer) {
+			return true
+		}
+	}
+	return false
+}
+
+// firewallBlocksWithin - Kalit ichidagi, firewallga qo'shilgan bo'lishi mumkin bo'lgan amaldagi bloklar.
+// Mutex ushlab turilgan bo'lishi kerak.
+func (b *IPBlocker) firewallBlocksWithin(key string, now time.Time) []string {
+	var keys []string
+	for inner, block := range b.blockedIPs {
+		if block.activeAt(now) && networkCovers(key, inner) && !overlapsAny(b.allowlist, inner) {
+			keys = append(keys, inner)
+		}
+	}
+	return keys
+}
+
+// networkCovers - outer tarmoq inner ni to'liq qamrab oladimi va undan kengroqmi
+func networkCovers(outer, inner string) bool {
+	if !strings.Contains(outer, "/") {
+		return false
+	}
+	outerNet, err := ParseCIDR(outer)
+	if err != nil {
+		return false
+	}
+	innerNet, err := ParseCIDR(inner)
+	if err != nil {
+		return false
+	}
+
+	outerOnes, outerBits := outerNet.Mask.Size()
+	innerOnes, innerBits := innerNet.Mask.Size()
+	return outerBits == innerBits && outerOnes < innerOnes && outerNet.Contains(innerNet.IP)
+}
+
+// networkOnes - IP manzil yoki subnet prefiksi uzunligi
+func networkOnes(key string) int {
+	network, err := ParseCIDR(key)
+	if err != nil {
+		return 0
+	}
+	ones, _ := network.Mask.Size()
+	return ones
+}
+
+// writeLog - Log fayliga yozish
+func (b *IPBlocker) writeLog(entry string) {
+	if _, err := b.logFile.WriteString(entry); err != nil {
+		log.Printf("Log faylga yozishda xatolik: %v", err)
+	}
+}
+
+// blockDurationText - Log uchun blok muddati
+func blockDurationText(block Block) string {
+	if block.Permanent() {
+		return "muddatsiz"
 	}
+	return block.ExpiresAt.Sub(block.BlockedAt).Round(time.Second).String()
 }
 
 // Close - IPBlocker resurslarini yopish
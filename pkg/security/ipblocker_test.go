@@ -0,0 +1,123 @@
+package security
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFirewall - Kesishadigan yozuvlarni nftables interval seti kabi rad etadigan xotiradagi firewall
+type fakeFirewall struct {
+	mu      sync.Mutex
+	entries map[string]time.Duration
+}
+
+func newFakeFirewall() *fakeFirewall {
+	return &fakeFirewall{entries: make(map[string]time.Duration)}
+}
+
+func (f *fakeFirewall) Block(ip string, timeout time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	network, err := ParseCIDR(ip)
+	if err != nil {
+		return err
+	}
+	for existing := range f.entries {
+		if existing != ip && overlapsAny([]*net.IPNet{network}, existing) {
+			return fmt.Errorf("conflicting intervals: %s va %s", existing, ip)
+		}
+	}
+	f.entries[ip] = timeout
+	return nil
+}
+
+func (f *fakeFirewall) Unblock(ip string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.entries[ip]; !exists {
+		return fmt.Errorf("element %s topilmadi", ip)
+	}
+	delete(f.entries, ip)
+	return nil
+}
+
+// keys - Firewalldagi yozuvlar (saralangan)
+func (f *fakeFirewall) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make([]string, 0, len(f.entries))
+	for key := range f.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// newTestBlocker - Vaqtinchalik log fayli va berilgan firewall bilan IPBlocker
+func newTestBlocker(t *testing.T, policy BlockPolicy, firewall Firewall) *IPBlocker {
+	t.Helper()
+
+	blocker, err := NewIPBlocker(policy, filepath.Join(t.TempDir(), "blocked.log"), nil, firewall)
+	if err != nil {
+		t.Fatalf("NewIPBlocker xatolik: %v", err)
+	}
+	t.Cleanup(func() { blocker.Close() })
+	return blocker
+}
+
+func TestIPBlockerFirewallSubnetReplacesCoveredHosts(t *testing.T) {
+	firewall := newFakeFirewall()
+	blocker := newTestBlocker(t, BlockPolicy{
+		MaxAttempts:       2,
+		BanDurations:      []time.Duration{time.Hour},
+		SubnetMaxAttempts: 3,
+		IPv4Prefix:        24,
+	}, firewall)
+
+	// 203.0.113.5 avval bloklanadi, uchinchi urinishda esa butun /24
+	blocker.RecordFailedAttempt("203.0.113.5", "test", "/")
+	blocker.RecordFailedAttempt("203.0.113.5", "test", "/")
+	if got := firewall.keys(); !reflect.DeepEqual(got, []string{"203.0.113.5"}) {
+		t.Fatalf("firewall = %v, kutilgan [203.0.113.5]", got)
+	}
+
+	blocker.RecordFailedAttempt("203.0.113.6", "test", "/")
+	if got := firewall.keys(); !reflect.DeepEqual(got, []string{"203.0.113.0/24"}) {
+		t.Fatalf("firewall = %v, kutilgan [203.0.113.0/24]", got)
+	}
+
+	// Subnet ichidagi yangi blok firewallga alohida qo'shilmaydi, lekin HTTP darajasida ishlaydi
+	if _, err := blocker.BlockIP("203.0.113.7", time.Hour, BlockReasonManual); err != nil {
+		t.Fatalf("BlockIP xatolik: %v", err)
+	}
+	if got := firewall.keys(); !reflect.DeepEqual(got, []string{"203.0.113.0/24"}) {
+		t.Errorf("firewall = %v, kutilgan [203.0.113.0/24]", got)
+	}
+
+	// Subnet blokdan chiqarilganda uning ichidagi amaldagi bloklar firewallga qaytadi
+	if !blocker.Unblock("203.0.113.0/24") {
+		t.Fatal("Unblock false qaytardi")
+	}
+	if got := firewall.keys(); !reflect.DeepEqual(got, []string{"203.0.113.5", "203.0.113.7"}) {
+		t.Errorf("firewall = %v, kutilgan [203.0.113.5 203.0.113.7]", got)
+	}
+}
+
+func TestIPBlockerFirewallSkipsExpiredBlock(t *testing.T) {
+	firewall := newFakeFirewall()
+	blocker := newTestBlocker(t, BlockPolicy{MaxAttempts: 1, BanDurations: []time.Duration{time.Hour}}, firewall)
+
+	blocker.firewallBlock(Block{IP: "198.51.100.1", BlockedAt: time.Now().Add(-time.Hour), ExpiresAt: time.Now().Add(-time.Second)})
+	if got := firewall.keys(); len(got) != 0 {
+		t.Errorf("firewall = %v, muddati o'tgan blok qo'shilmasligi kerak", got)
+	}
+}
@@ -0,0 +1,186 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyV2Signature - PROXY protocol v2 sarlavhasining boshlanishi
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyV1MaxLength - PROXY protocol v1 satrining maksimal uzunligi (CRLF bilan)
+const proxyV1MaxLength = 107
+
+// ProxyProtocolListener - Ishonchli proxylardan kelgan ulanishlarda PROXY protocol (v1 va v2) sarlavhasini o'qib,
+// RemoteAddr sifatida asl client manzilini qaytaruvchi listener. Boshqa manzillardan kelgan sarlavhalar
+// qabul qilinmaydi, ya'ni ular oddiy TCP ulanish sifatida ishlanadi.
+type ProxyProtocolListener struct {
+	net.Listener
+	Trusted []*net.IPNet  // PROXY sarlavhasi qabul qilinadigan proxylar
+	Timeout time.Duration // Sarlavhani kutishning maksimal vaqti
+}
+
+// Accept - Ulanishni qabul qilish. Sarlavha accept siklini to'xtatmaslik uchun birinchi o'qishda o'qiladi.
+func (l *ProxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil || !containsIP(l.Trusted, host) {
+		return conn, nil
+	}
+	return &proxyConn{Conn: conn, reader: bufio.NewReader(conn), timeout: l.Timeout}, nil
+}
+
+// proxyConn - PROXY protocol sarlavhasi bilan boshlanishi mumkin bo'lgan ulanish
+type proxyConn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+// readHeader - Sarlavhani bir marta o'qish
+func (c *proxyConn) readHeader() {
+	c.once.Do(func() {
+		if c.timeout > 0 {
+			c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+			defer c.Conn.SetReadDeadline(time.Time{})
+		}
+
+		c.remote, c.err = readProxyHeader(c.reader)
+		if c.err != nil {
+			log.Printf("PROXY protocol: %s dan noto'g'ri sarlavha: %v", c.Conn.RemoteAddr(), c.err)
+		}
+	})
+}
+
+// Read - Sarlavhadan keyingi ma'lumotlarni o'qish
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr - Sarlavhadagi client manzili (sarlavha bo'lmasa proxyning o'z manzili)
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader - v1 yoki v2 sarlavhasini o'qish. Sarlavha bo'lmasa yoki proxy o'zining
+// tekshiruv ulanishi (LOCAL/UNKNOWN) bo'lsa nil manzil qaytariladi.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch first[0] {
+	case 'P':
+		prefix, err := r.Peek(6)
+		if err != nil || string(prefix) != "PROXY " {
+			return nil, nil
+		}
+		return readProxyV1(r)
+	case '\r':
+		signature, err := r.Peek(len(proxyV2Signature))
+		if err != nil || !bytes.Equal(signature, proxyV2Signature) {
+			return nil, nil
+		}
+		return readProxyV2(r)
+	}
+	return nil, nil
+}
+
+// readProxyV1 - "PROXY TCP4 <src> <dst> <sport> <dport>\r\n" satrini o'qish
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < proxyV1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("v1 sarlavhasi juda uzun yoki CRLF bilan tugamagan")
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("noto'g'ri v1 sarlavhasi: %q", strings.TrimSpace(string(line)))
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.Atoi(fields[4])
+	if ip == nil || err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("noto'g'ri v1 manzili: %s:%s", fields[2], fields[4])
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// readProxyV2 - Binary v2 sarlavhasini o'qish
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("qo'llab-quvvatlanmaydigan v2 versiyasi: %d", header[12]>>4)
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	// LOCAL buyrug'i - proxyning o'z ulanishi (masalan health check)
+	if header[12]&0x0f == 0 {
+		return nil, nil
+	}
+	if header[12]&0x0f != 1 {
+		return nil, fmt.Errorf("noma'lum v2 buyrug'i: %d", header[12]&0x0f)
+	}
+
+	switch header[13] >> 4 {
+	case 1: // AF_INET
+		if len(payload) < 12 {
+			return nil, fmt.Errorf("v2 IPv4 manzillari qisqa")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 2: // AF_INET6
+		if len(payload) < 36 {
+			return nil, fmt.Errorf("v2 IPv6 manzillari qisqa")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	}
+
+	// AF_UNSPEC yoki AF_UNIX - manzil yo'q
+	return nil, nil
+}
@@ -0,0 +1,180 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// proxyV2Header - v2 sarlavhasini yaratish (versiya va buyruq, oila va protokol, manzillar)
+func proxyV2Header(versionCommand, family byte, addresses []byte) []byte {
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, versionCommand, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addresses)))
+	return append(header, addresses...)
+}
+
+// v2Addresses - Manbaa va manzil IP/portlarini v2 formatida yozish
+func v2Addresses(src, dst string, srcPort, dstPort uint16) []byte {
+	srcIP, dstIP := net.ParseIP(src), net.ParseIP(dst)
+	if v4 := srcIP.To4(); v4 != nil {
+		srcIP, dstIP = v4, dstIP.To4()
+	}
+
+	var b []byte
+	b = append(b, srcIP...)
+	b = append(b, dstIP...)
+	b = binary.BigEndian.AppendUint16(b, srcPort)
+	return binary.BigEndian.AppendUint16(b, dstPort)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		wantAddr string // Bo'sh - manzil qaytarilmasligi kerak
+		wantErr  bool
+		wantRest string // Sarlavhadan keyin o'qilishi kerak bo'lgan ma'lumot
+	}{
+		{
+			name:     "v1 TCP4",
+			input:    []byte("PROXY TCP4 203.0.113.7 10.0.0.1 51234 443\r\nGET / HTTP/1.1"),
+			wantAddr: "203.0.113.7:51234",
+			wantRest: "GET / HTTP/1.1",
+		},
+		{
+			name:     "v1 TCP6",
+			input:    []byte("PROXY TCP6 2001:db8::7 2001:db8::1 51234 443\r\nbody"),
+			wantAddr: "[2001:db8::7]:51234",
+			wantRest: "body",
+		},
+		{
+			name:     "v1 UNKNOWN",
+			input:    []byte("PROXY UNKNOWN\r\nbody"),
+			wantRest: "body",
+		},
+		{
+			name:    "v1 noto'g'ri manzil",
+			input:   []byte("PROXY TCP4 not-an-ip 10.0.0.1 51234 443\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 noto'g'ri port",
+			input:   []byte("PROXY TCP4 203.0.113.7 10.0.0.1 70000 443\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 maydonlar yetishmaydi",
+			input:   []byte("PROXY TCP4 203.0.113.7\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 CRLF siz",
+			input:   []byte("PROXY TCP4 203.0.113.7 10.0.0.1 51234 443\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 juda uzun",
+			input:   []byte("PROXY TCP4 " + strings.Repeat("1", proxyV1MaxLength) + "\r\n"),
+			wantErr: true,
+		},
+		{
+			name:     "v2 IPv4",
+			input:    append(proxyV2Header(0x21, 0x11, v2Addresses("198.51.100.9", "10.0.0.1", 40000, 443)), "body"...),
+			wantAddr: "198.51.100.9:40000",
+			wantRest: "body",
+		},
+		{
+			name:     "v2 IPv6",
+			input:    append(proxyV2Header(0x21, 0x21, v2Addresses("2001:db8::9", "2001:db8::1", 40000, 443)), "body"...),
+			wantAddr: "[2001:db8::9]:40000",
+			wantRest: "body",
+		},
+		{
+			name:     "v2 qo'shimcha TLV lar o'tkazib yuboriladi",
+			input:    append(proxyV2Header(0x21, 0x11, append(v2Addresses("198.51.100.9", "10.0.0.1", 40000, 443), 0x04, 0x00, 0x01, 0xff)), "body"...),
+			wantAddr: "198.51.100.9:40000",
+			wantRest: "body",
+		},
+		{
+			name:     "v2 LOCAL",
+			input:    append(proxyV2Header(0x20, 0x00, nil), "body"...),
+			wantRest: "body",
+		},
+		{
+			name:     "v2 AF_UNSPEC",
+			input:    append(proxyV2Header(0x21, 0x00, nil), "body"...),
+			wantRest: "body",
+		},
+		{
+			name:    "v2 noto'g'ri versiya",
+			input:   proxyV2Header(0x11, 0x11, v2Addresses("198.51.100.9", "10.0.0.1", 40000, 443)),
+			wantErr: true,
+		},
+		{
+			name:    "v2 noma'lum buyruq",
+			input:   proxyV2Header(0x22, 0x11, v2Addresses("198.51.100.9", "10.0.0.1", 40000, 443)),
+			wantErr: true,
+		},
+		{
+			name:    "v2 IPv4 manzillari qisqa",
+			input:   proxyV2Header(0x21, 0x11, []byte{198, 51, 100, 9}),
+			wantErr: true,
+		},
+		{
+			name:    "v2 kesilgan sarlavha",
+			input:   proxyV2Header(0x21, 0x11, v2Addresses("198.51.100.9", "10.0.0.1", 40000, 443))[:20],
+			wantErr: true,
+		},
+		{
+			name:     "sarlavhasiz HTTP",
+			input:    []byte("GET / HTTP/1.1"),
+			wantRest: "GET / HTTP/1.1",
+		},
+		{
+			name:     "P bilan boshlanuvchi boshqa so'rov",
+			input:    []byte("POST / HTTP/1.1"),
+			wantRest: "POST / HTTP/1.1",
+		},
+		{
+			name:     "CR bilan boshlanuvchi boshqa ma'lumot",
+			input:    []byte("\r\nhello"),
+			wantRest: "\r\nhello",
+		},
+		{
+			name:    "bo'sh ulanish",
+			input:   nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(bytes.NewReader(tt.input))
+			addr, err := readProxyHeader(reader)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readProxyHeader() xatolik = %v, kutilgan xatolik: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var got string
+			if addr != nil {
+				got = addr.String()
+			}
+			if got != tt.wantAddr {
+				t.Errorf("manzil = %q, kutilgan %q", got, tt.wantAddr)
+			}
+
+			rest, _ := io.ReadAll(reader)
+			if string(rest) != tt.wantRest {
+				t.Errorf("qolgan ma'lumot = %q, kutilgan %q", rest, tt.wantRest)
+			}
+		})
+	}
+}
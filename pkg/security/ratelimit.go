@@ -0,0 +1,156 @@
+package security
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimitCleanupInterval - To'lib qolgan (ya'ni yangisidan farqi yo'q) bucketlarni o'chirish oralig'i
+const rateLimitCleanupInterval = 10 * time.Minute
+
+// RateLimit - Token bucket chegarasi
+type RateLimit struct {
+	PerMinute int // Minutiga qo'shiladigan tokenlar (0 = cheklanmagan)
+	Burst     int // Bucket sig'imi - ketma-ket yuborish mumkin bo'lgan so'rovlar
+}
+
+// Unlimited - Chegara o'chirilganmi
+func (l RateLimit) Unlimited() bool {
+	return l.PerMinute <= 0
+}
+
+// Window - Bo'sh bucket to'liq to'lishi uchun kerak bo'lgan vaqt
+func (l RateLimit) Window() time.Duration {
+	return time.Duration(float64(l.capacity()) / float64(l.PerMinute) * float64(time.Minute))
+}
+
+// capacity - Bucket sig'imi (burst ko'rsatilmasa tezlikka teng)
+func (l RateLimit) capacity() int {
+	if l.Burst <= 0 {
+		return l.PerMinute
+	}
+	return l.Burst
+}
+
+// RateLimitResult - Bitta so'rovni tekshirish natijasi
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int           // Bucket sig'imi
+	Remaining  int           // Qolgan tokenlar
+	Reset      time.Duration // Bucket to'liq to'lishigacha qolgan vaqt
+	RetryAfter time.Duration // Rad etilgan bo'lsa keyingi token paydo bo'lishigacha qolgan vaqt
+}
+
+// tokenBucket - Bitta so'rov egasi va guruh uchun bucket
+type tokenBucket struct {
+	tokens    float64
+	updated   time.Time
+	perSecond float64
+	burst     float64
+}
+
+// RateLimiter - Token bucket algoritmi bo'yicha so'rovlar chastotasini cheklovchi
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewRateLimiter - Yangi RateLimiter yaratish
+func NewRateLimiter() *RateLimiter {
+	limiter := &RateLimiter{buckets: make(map[string]*tokenBucket)}
+
+	// Ishlatilmayotgan bucketlarni tozalash uchun goroutine ishga tushirish
+	go limiter.cleanupIdleBuckets()
+
+	return limiter
+}
+
+// Allow - key bucketidan bitta token olishga urinish
+func (r *RateLimiter) Allow(key string, limit RateLimit) RateLimitResult {
+	return r.AllowAll([]string{key}, []RateLimit{limit})[0]
+}
+
+// AllowAll - Bir nechta bucketning har biridan bitta token olishga urinish. Tokenlar faqat barcha
+// bucketlarda yetarli bo'lsa olinadi: bitta chegara rad etsa boshqalari behuda sarflanmaydi (barcha natijalarda Allowed bir xil).
+func (r *RateLimiter) AllowAll(keys []string, limits []RateLimit) []RateLimitResult {
+	results := make([]RateLimitResult, len(keys))
+	buckets := make([]*tokenBucket, len(keys))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	allowed := true
+	for i, key := range keys {
+		limit := limits[i]
+		if limit.Unlimited() {
+			continue
+		}
+
+		burst := limit.capacity()
+
+		bucket, exists := r.buckets[key]
+		if !exists {
+			bucket = &tokenBucket{tokens: float64(burst), updated: now}
+			r.buckets[key] = bucket
+		}
+
+		// Oxirgi so'rovdan beri to'plangan tokenlar (chegara kamaytirilgan bo'lsa ortiqchasi tashlanadi)
+		bucket.perSecond = float64(limit.PerMinute) / 60
+		bucket.burst = float64(burst)
+		bucket.tokens = bucket.level(now)
+		bucket.updated = now
+
+		buckets[i] = bucket
+		results[i].Limit = burst
+		if bucket.tokens < 1 {
+			allowed = false
+		}
+	}
+
+	for i, bucket := range buckets {
+		if bucket == nil {
+			results[i].Allowed = allowed
+			continue
+		}
+
+		if allowed {
+			bucket.tokens--
+			results[i].Allowed = true
+		} else if bucket.tokens < 1 {
+			results[i].RetryAfter = secondsDuration((1 - bucket.tokens) / bucket.perSecond)
+		}
+
+		results[i].Remaining = int(bucket.tokens)
+		results[i].Reset = secondsDuration((bucket.burst - bucket.tokens) / bucket.perSecond)
+	}
+	return results
+}
+
+// level - Berilgan vaqtdagi tokenlar soni
+func (b *tokenBucket) level(now time.Time) float64 {
+	return math.Min(b.burst, b.tokens+now.Sub(b.updated).Seconds()*b.perSecond)
+}
+
+// cleanupIdleBuckets - To'lib qolgan bucketlarni o'chirish (keyingi so'rovda ular to'liq holda qayta yaratiladi)
+func (r *RateLimiter) cleanupIdleBuckets() {
+	ticker := time.NewTicker(rateLimitCleanupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		r.mu.Lock()
+		now := time.Now()
+		for key, bucket := range r.buckets {
+			if bucket.level(now) >= bucket.burst {
+				delete(r.buckets, key)
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+// secondsDuration - Soniyalarni time.Duration ga o'tkazish
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,169 @@
+package security
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	tests := []struct {
+		name          string
+		limit         RateLimit
+		requests      int
+		wantAllowed   int
+		wantLimit     int
+		wantRemaining int
+	}{
+		{"burst ichida", RateLimit{PerMinute: 1, Burst: 5}, 3, 3, 5, 2},
+		{"burst tugaydi", RateLimit{PerMinute: 1, Burst: 5}, 8, 5, 5, 0},
+		{"burst ko'rsatilmasa tezlikka teng", RateLimit{PerMinute: 3}, 5, 3, 3, 0},
+		{"cheklanmagan", RateLimit{}, 100, 100, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter()
+
+			var allowed int
+			var last RateLimitResult
+			for i := 0; i < tt.requests; i++ {
+				last = limiter.Allow("key", tt.limit)
+				if last.Allowed {
+					allowed++
+				}
+			}
+
+			if allowed != tt.wantAllowed {
+				t.Errorf("ruxsat berilgan so'rovlar = %d, kutilgan %d", allowed, tt.wantAllowed)
+			}
+			if last.Limit != tt.wantLimit || last.Remaining != tt.wantRemaining {
+				t.Errorf("Limit/Remaining = %d/%d, kutilgan %d/%d", last.Limit, last.Remaining, tt.wantLimit, tt.wantRemaining)
+			}
+			if !last.Allowed && (last.RetryAfter <= 0 || last.RetryAfter > time.Minute) {
+				t.Errorf("RetryAfter = %s, 0 dan katta va 1 minutdan kichik bo'lishi kerak", last.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestRateLimiterKeysAreIndependent(t *testing.T) {
+	limiter := NewRateLimiter()
+	limit := RateLimit{PerMinute: 1, Burst: 1}
+
+	if !limiter.Allow("a", limit).Allowed {
+		t.Fatal("a uchun birinchi so'rov rad etildi")
+	}
+	if limiter.Allow("a", limit).Allowed {
+		t.Fatal("a uchun ikkinchi so'rov ruxsat berildi")
+	}
+	if !limiter.Allow("b", limit).Allowed {
+		t.Fatal("b bucketi a bilan umumiy bo'lmasligi kerak")
+	}
+}
+
+func TestRateLimiterAllowAll(t *testing.T) {
+	tests := []struct {
+		name          string
+		limits        []RateLimit
+		requests      int
+		wantAllowed   int
+		wantRemaining []int
+	}{
+		{
+			name:          "qattiqroq chegara boshqasini sarflamaydi",
+			limits:        []RateLimit{{PerMinute: 1, Burst: 10}, {PerMinute: 1, Burst: 2}},
+			requests:      5,
+			wantAllowed:   2,
+			wantRemaining: []int{8, 0},
+		},
+		{
+			name:          "cheklanmagan guruh hisobga olinmaydi",
+			limits:        []RateLimit{{}, {PerMinute: 1, Burst: 3}},
+			requests:      4,
+			wantAllowed:   3,
+			wantRemaining: []int{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter()
+			keys := []string{"write", "client_create"}
+
+			var allowed int
+			var results []RateLimitResult
+			for i := 0; i < tt.requests; i++ {
+				results = limiter.AllowAll(keys, tt.limits)
+				if results[0].Allowed != results[1].Allowed {
+					t.Fatalf("so'rov %d: natijalar bir xil bo'lishi kerak: %+v", i, results)
+				}
+				if results[0].Allowed {
+					allowed++
+				}
+			}
+
+			if allowed != tt.wantAllowed {
+				t.Errorf("ruxsat berilgan so'rovlar = %d, kutilgan %d", allowed, tt.wantAllowed)
+			}
+			for i, result := range results {
+				if result.Remaining != tt.wantRemaining[i] {
+					t.Errorf("%s Remaining = %d, kutilgan %d", keys[i], result.Remaining, tt.wantRemaining[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterLoweredLimit(t *testing.T) {
+	limiter := NewRateLimiter()
+	limiter.Allow("key", RateLimit{PerMinute: 1, Burst: 10})
+
+	// Chegara kamaytirilganda to'plangan tokenlar yangi burstdan oshmaydi
+	result := limiter.Allow("key", RateLimit{PerMinute: 1, Burst: 3})
+	if !result.Allowed || result.Limit != 3 || result.Remaining != 2 {
+		t.Errorf("natija = %+v, kutilgan Allowed, Limit 3, Remaining 2", result)
+	}
+}
+
+func TestTokenBucketLevel(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		tokens  float64
+		elapsed time.Duration
+		want    float64
+	}{
+		{"vaqt o'tmagan", 0, 0, 0},
+		{"yarim minut", 0, 30 * time.Second, 30},
+		{"qisman to'lgan", 50, 20 * time.Second, 70},
+		{"burstdan oshmaydi", 90, time.Minute, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := &tokenBucket{tokens: tt.tokens, updated: start, perSecond: 1, burst: 100}
+			if got := bucket.level(start.Add(tt.elapsed)); got != tt.want {
+				t.Errorf("level() = %v, kutilgan %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitWindow(t *testing.T) {
+	tests := []struct {
+		limit RateLimit
+		want  time.Duration
+	}{
+		{RateLimit{PerMinute: 60, Burst: 60}, time.Minute},
+		{RateLimit{PerMinute: 120, Burst: 30}, 15 * time.Second},
+		{RateLimit{PerMinute: 10, Burst: 5}, 30 * time.Second},
+		{RateLimit{PerMinute: 600}, time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := tt.limit.Window(); got != tt.want {
+			t.Errorf("%+v Window() = %s, kutilgan %s", tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wireguard-vpn-client-creater/pkg/config"
+	"wireguard-vpn-client-creater/pkg/database"
+	"wireguard-vpn-client-creater/pkg/events"
+	"wireguard-vpn-client-creater/pkg/models"
+)
+
+// Webhook orqali yuboriladigan hodisa turlari
+var deliverableEvents = map[string]bool{
+	events.ClientCreated:       true,
+	events.ClientDeleted:       true,
+	events.ClientExpired:       true,
+	events.ClientExpiringSoon:  true,
+	events.ClientQuotaExceeded: true,
+	events.AuthIPBlocked:       true,
+}
+
+// Webhook so'rovi headerlari
+const (
+	SignatureHeader = "X-Webhook-Signature" // "sha256=<hex>" - body ning HMAC-SHA256 imzosi
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+const (
+	pollInterval   = 5 * time.Second
+	requestTimeout = 10 * time.Second
+	batchSize      = 50
+	baseBackoff    = 30 * time.Second
+	maxBackoff     = time.Hour
+)
+
+var (
+	httpClient = &http.Client{Timeout: requestTimeout}
+	wakeup     = make(chan struct{}, 1)
+)
+
+// Start - Hodisalarni navbatga yozuvchi va ularni yetkazuvchi jarayonlarni ishga tushirish
+func Start() {
+	if len(config.Config.Webhooks.Endpoints) == 0 {
+		return
+	}
+
+	// Yetkazishlar hodisa yuborilgan joyda sinxron yoziladi: obunachi navbati to'lganda
+	// hodisani tashlab yuboradigan shina faqat SSE uchun
+	events.Default.AddHook(func(event events.Event) {
+		if deliverableEvents[event.Type] {
+			enqueue(event)
+		}
+	})
+
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			deliverDue()
+			select {
+			case <-ticker.C:
+			case <-wakeup:
+			}
+		}
+	}()
+}
+
+// Sign - Body uchun HMAC-SHA256 imzo yaratish
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// enqueue - Hodisani unga obuna bo'lgan har bir endpoint uchun databasega yozish
+func enqueue(event events.Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Webhook: hodisani JSON ga o'tkazishda xatolik: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, endpoint := range config.Config.Webhooks.Endpoints {
+		if !subscribed(endpoint, event.Type) {
+			continue
+		}
+
+		delivery := &models.WebhookDelivery{
+			URL:           endpoint.URL,
+			EventType:     event.Type,
+			Payload:       string(payload),
+			NextAttemptAt: &now,
+		}
+		if err := database.CreateWebhookDelivery(delivery); err != nil {
+			log.Printf("Webhook: %s uchun yetkazishni saqlashda xatolik: %v", endpoint.URL, err)
+		}
+	}
+
+	select {
+	case wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// subscribed - Endpoint berilgan hodisa turiga obuna bo'lganini tekshirish
+func subscribed(endpoint config.WebhookEndpoint, eventType string) bool {
+	if len(endpoint.Events) == 0 {
+		return true
+	}
+	for _, e := range endpoint.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// deliverDue - Vaqti kelgan barcha yetkazishlarni yuborish
+func deliverDue() {
+	deliveries, err := database.GetDueWebhookDeliveries(time.Now(), batchSize)
+	if err != nil {
+		log.Printf("Webhook: navbatni o'qishda xatolik: %v", err)
+		return
+	}
+
+	for i := range deliveries {
+		attempt(&deliveries[i])
+	}
+}
+
+// attempt - Bitta yetkazishni yuborish va natijani saqlash
+func attempt(delivery *models.WebhookDelivery) {
+	delivery.Attempts++
+
+	endpoint, ok := findEndpoint(delivery.URL)
+	if !ok {
+		// Endpoint konfiguratsiyadan olib tashlangan
+		fail(delivery, "endpoint konfiguratsiyada topilmadi")
+		return
+	}
+
+	status, err := send(endpoint, delivery)
+	delivery.LastStatus = status
+	if err == nil {
+		now := time.Now()
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+		delivery.LastError = ""
+	} else if delivery.Attempts >= maxAttempts() {
+		fail(delivery, err.Error())
+		return
+	} else {
+		next := time.Now().Add(backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+		delivery.LastError = err.Error()
+	}
+
+	if err := database.UpdateWebhookDelivery(delivery); err != nil {
+		log.Printf("Webhook: yetkazish %d ni saqlashda xatolik: %v", delivery.ID, err)
+	}
+}
+
+// send - Payloadni imzo bilan endpointga POST qilish
+func send(endpoint config.WebhookEndpoint, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "wireguard-vpn-server-webhook")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	if endpoint.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(endpoint.Secret, body))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint %d status qaytardi", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// fail - Yetkazishni muvaffaqiyatsiz deb belgilash (qayta urinilmaydi)
+func fail(delivery *models.WebhookDelivery, reason string) {
+	now := time.Now()
+	delivery.FailedAt = &now
+	delivery.NextAttemptAt = nil
+	delivery.LastError = reason
+
+	log.Printf("Webhook: %s hodisasini %s ga yetkazib bo'lmadi (%d urinish): %s",
+		delivery.EventType, delivery.URL, delivery.Attempts, reason)
+	if err := database.UpdateWebhookDelivery(delivery); err != nil {
+		log.Printf("Webhook: yetkazish %d ni saqlashda xatolik: %v", delivery.ID, err)
+	}
+}
+
+// findEndpoint - URL bo'yicha endpoint konfiguratsiyasini topish (secret yangilangan bo'lishi mumkin)
+func findEndpoint(url string) (config.WebhookEndpoint, bool) {
+	for _, endpoint := range config.Config.Webhooks.Endpoints {
+		if endpoint.URL == url {
+			return endpoint, true
+		}
+	}
+	return config.WebhookEndpoint{}, false
+}
+
+// maxAttempts - Konfiguratsiyadan urinishlar sonini olish
+func maxAttempts() int {
+	if config.Config.Webhooks.MaxAttempts > 0 {
+		return config.Config.Webhooks.MaxAttempts
+	}
+	return 8
+}
+
+// backoff - Keyingi urinishgacha kutish vaqti (30s, 1m, 2m, ... 1 soatgacha)
+func backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"wireguard-vpn-client-creater/pkg/config"
+	"wireguard-vpn-client-creater/pkg/events"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		body   string
+		want   string
+	}{
+		{
+			// RFC 4231, 2-test
+			name:   "rfc4231",
+			secret: "Jefe",
+			body:   "what do ya want for nothing?",
+			want:   "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		},
+		{
+			name:   "bo'sh body",
+			secret: "webhook-secret",
+			body:   "",
+			want:   "sha256=1001c524b535a8aef76c14f33c851aa4a586e98253232319990ba8e2be464ba9",
+		},
+		{
+			name:   "json payload",
+			secret: "webhook-secret",
+			body:   `{"id":1,"type":"client.created"}`,
+			want:   "sha256=ece22ed8fb0fbbd337c50ab34e29e6bb67044829004f6de8e6dacc497af533c1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, []byte(tt.body)); got != tt.want {
+				t.Errorf("Sign() = %s, kutilgan %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignDependsOnSecret(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	if Sign("a", body) == Sign("b", body) {
+		t.Error("turli kalitlar bilan imzolar bir xil bo'lmasligi kerak")
+	}
+}
+
+func TestSubscribed(t *testing.T) {
+	tests := []struct {
+		name      string
+		events    []string
+		eventType string
+		want      bool
+	}{
+		{"ro'yxat bo'sh - barcha hodisalar", nil, events.ClientCreated, true},
+		{"ro'yxatda bor", []string{events.ClientCreated, events.ClientExpired}, events.ClientExpired, true},
+		{"ro'yxatda yo'q", []string{events.ClientCreated}, events.ClientDeleted, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := config.WebhookEndpoint{URL: "https://example.com", Events: tt.events}
+			if got := subscribed(endpoint, tt.eventType); got != tt.want {
+				t.Errorf("subscribed(%v, %s) = %v, kutilgan %v", tt.events, tt.eventType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{50, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, kutilgan %s", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package wireguard
+
+import (
+	"fmt"
+	"time"
+
+	"wireguard-vpn-client-creater/pkg/config"
+)
+
+// Backend turlari
+const (
+	// BackendExec - wg va wg-quick buyruqlari orqali ishlash
+	BackendExec = "exec"
+	// BackendNetlink - wgctrl/netlink orqali to'g'ridan-to'g'ri kernel bilan ishlash
+	BackendNetlink = "netlink"
+	// BackendMemory - xotirada ishlaydigan soxta backend (test va development uchun)
+	BackendMemory = "memory"
+)
+
+// Peer - Interfacega qo'shiladigan peer ma'lumotlari
+type Peer struct {
+	PublicKey    string
+	PresharedKey string
+	AllowedIPs   []string
+}
+
+// PeerStats - Interfacedagi peer holati va statistikasi
+type PeerStats struct {
+	PublicKey           string
+	PresharedKey        string
+	Endpoint            string
+	AllowedIPs          []string
+	LatestHandshake     time.Time
+	BytesReceived       int64
+	BytesSent           int64
+	PersistentKeepalive int
+}
+
+// InterfaceInfo - Wireguard interface ma'lumotlari
+type InterfaceInfo struct {
+	Name       string
+	PublicKey  string
+	ListenPort int
+}
+
+// Backend - Wireguard interfacesi bilan ishlash uchun interface
+type Backend interface {
+	// AddPeer - peerni qo'shish yoki mavjud peerni yangilash (allowed-ips almashtiriladi)
+	AddPeer(peer Peer) error
+	// RemovePeer - peerni interfacedan o'chirish
+	RemovePeer(publicKey string) error
+	// ListPeers - interfacedagi barcha peerlar va ularning statistikasini olish
+	ListPeers() ([]PeerStats, error)
+	// InterfaceInfo - interface haqida ma'lumot olish
+	InterfaceInfo() (*InterfaceInfo, error)
+}
+
+// Current - Konfiguratsiyada tanlangan global backend
+var Current Backend
+
+// InitBackend - Konfiguratsiyaga qarab global backendni yaratish
+func InitBackend(kind string) (Backend, error) {
+	backend, err := NewBackend(kind, interfaceName())
+	if err != nil {
+		return nil, err
+	}
+
+	Current = backend
+	return backend, nil
+}
+
+// NewBackend - Berilgan turdagi backendni yaratish
+func NewBackend(kind, iface string) (Backend, error) {
+	switch kind {
+	case "", BackendExec:
+		return NewExecBackend(iface), nil
+	case BackendNetlink:
+		return NewNetlinkBackend(iface)
+	case BackendMemory:
+		return NewMemoryBackend(iface), nil
+	default:
+		return nil, fmt.Errorf("noma'lum wireguard backend: %s", kind)
+	}
+}
+
+// interfaceName - Konfiguratsiyadan interface nomini olish
+func interfaceName() string {
+	if config.Config.Server.Interface == "" {
+		return "wg0"
+	}
+	return config.Config.Server.Interface
+}
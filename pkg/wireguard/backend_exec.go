@@ -0,0 +1,179 @@
+package wireguard
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExecBackend - wg va wg-quick buyruqlari orqali ishlaydigan backend
+type ExecBackend struct {
+	iface string
+}
+
+// NewExecBackend - Yangi ExecBackend yaratish
+func NewExecBackend(iface string) *ExecBackend {
+	return &ExecBackend{iface: iface}
+}
+
+// AddPeer - wg set orqali peer qo'shish va konfiguratsiyani saqlash
+func (b *ExecBackend) AddPeer(peer Peer) error {
+	// Noto'g'ri kalitlarni wg buyrug'iga yubormaslik
+	if err := ValidateKey(peer.PublicKey); err != nil {
+		return fmt.Errorf("public key noto'g'ri: %v", err)
+	}
+	if peer.PresharedKey != "" {
+		if err := ValidateKey(peer.PresharedKey); err != nil {
+			return fmt.Errorf("preshared key noto'g'ri: %v", err)
+		}
+	}
+
+	args := []string{"set", b.iface, "peer", peer.PublicKey}
+
+	// Preshared key faylini yaratish
+	if peer.PresharedKey != "" {
+		tempPresharedKeyFile, err := os.CreateTemp("", "psk")
+		if err != nil {
+			return fmt.Errorf("vaqtinchalik preshared key fayli yaratishda xatolik: %v", err)
+		}
+		defer os.Remove(tempPresharedKeyFile.Name())
+
+		_, err = tempPresharedKeyFile.WriteString(peer.PresharedKey)
+		if err != nil {
+			return fmt.Errorf("preshared key fayliga yozishda xatolik: %v", err)
+		}
+		tempPresharedKeyFile.Close()
+
+		args = append(args, "preshared-key", tempPresharedKeyFile.Name())
+	}
+
+	args = append(args, "allowed-ips", strings.Join(peer.AllowedIPs, ","))
+
+	cmd := exec.Command("wg", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("peer qo'shishda xatolik: %v, output: %s", err, string(output))
+	}
+
+	return b.save()
+}
+
+// RemovePeer - wg set orqali peerni o'chirish va konfiguratsiyani saqlash
+func (b *ExecBackend) RemovePeer(publicKey string) error {
+	if err := ValidateKey(publicKey); err != nil {
+		return fmt.Errorf("public key noto'g'ri: %v", err)
+	}
+
+	cmd := exec.Command("wg", "set", b.iface, "peer", publicKey, "remove")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("peerni o'chirishda xatolik: %v, output: %s", err, string(output))
+	}
+
+	return b.save()
+}
+
+// ListPeers - wg show dump orqali peerlar ro'yxatini olish
+func (b *ExecBackend) ListPeers() ([]PeerStats, error) {
+	lines, err := b.dump()
+	if err != nil {
+		return nil, err
+	}
+
+	// Birinchi qatorni o'tkazib yuborish (interface ma'lumotlari)
+	var peers []PeerStats
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 8 {
+			continue
+		}
+
+		// Handshake vaqtini o'zgartirish
+		var handshakeTime time.Time
+		handshakeUnix, _ := strconv.ParseInt(fields[4], 10, 64)
+		if handshakeUnix > 0 {
+			handshakeTime = time.Unix(handshakeUnix, 0)
+		}
+
+		bytesReceived, _ := strconv.ParseInt(fields[5], 10, 64)
+		bytesSent, _ := strconv.ParseInt(fields[6], 10, 64)
+		keepalive, _ := strconv.Atoi(fields[7])
+
+		var allowedIPs []string
+		if fields[3] != "(none)" {
+			allowedIPs = strings.Split(fields[3], ",")
+		}
+
+		peers = append(peers, PeerStats{
+			PublicKey:           fields[0],
+			PresharedKey:        noneToEmpty(fields[1]),
+			Endpoint:            noneToEmpty(fields[2]),
+			AllowedIPs:          allowedIPs,
+			LatestHandshake:     handshakeTime,
+			BytesReceived:       bytesReceived,
+			BytesSent:           bytesSent,
+			PersistentKeepalive: keepalive,
+		})
+	}
+
+	return peers, nil
+}
+
+// InterfaceInfo - wg show dump ning birinchi qatoridan interface ma'lumotlarini olish
+func (b *ExecBackend) InterfaceInfo() (*InterfaceInfo, error) {
+	lines, err := b.dump()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Split(lines[0], "\t")
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("server ma'lumotlari formati noto'g'ri")
+	}
+
+	listenPort, _ := strconv.Atoi(fields[2])
+
+	return &InterfaceInfo{
+		Name:       b.iface,
+		PublicKey:  fields[1],
+		ListenPort: listenPort,
+	}, nil
+}
+
+// dump - wg show dump natijasini qatorlarga ajratib olish
+func (b *ExecBackend) dump() ([]string, error) {
+	cmd := exec.Command("wg", "show", b.iface, "dump")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("wg show dump buyrug'ini ishga tushirishda xatolik: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) < 1 || lines[0] == "" {
+		return nil, fmt.Errorf("server ma'lumotlari topilmadi")
+	}
+
+	return lines, nil
+}
+
+// save - wg-quick save orqali o'zgarishlarni konfiguratsiya fayliga saqlash
+func (b *ExecBackend) save() error {
+	cmd := exec.Command("wg-quick", "save", b.iface)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("konfiguratsiyani saqlashda xatolik: %v, output: %s", err, string(output))
+	}
+
+	return nil
+}
+
+// noneToEmpty - wg dump dagi "(none)" qiymatini bo'sh satrga o'zgartirish
+func noneToEmpty(value string) string {
+	if value == "(none)" {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,87 @@
+package wireguard
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MemoryBackend - Xotirada ishlaydigan soxta backend.
+// Kernel yoki wireguard-tools talab qilinmaydi, shuning uchun test va development uchun qulay.
+type MemoryBackend struct {
+	iface string
+	peers map[string]PeerStats
+	mu    sync.RWMutex
+}
+
+// NewMemoryBackend - Yangi MemoryBackend yaratish
+func NewMemoryBackend(iface string) *MemoryBackend {
+	return &MemoryBackend{
+		iface: iface,
+		peers: make(map[string]PeerStats),
+	}
+}
+
+// AddPeer - Peerni xotiraga qo'shish yoki yangilash
+func (b *MemoryBackend) AddPeer(peer Peer) error {
+	if err := ValidateKey(peer.PublicKey); err != nil {
+		return fmt.Errorf("public key noto'g'ri: %v", err)
+	}
+	if peer.PresharedKey != "" {
+		if err := ValidateKey(peer.PresharedKey); err != nil {
+			return fmt.Errorf("preshared key noto'g'ri: %v", err)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Mavjud peerning statistikasini saqlab qolish
+	stats := b.peers[peer.PublicKey]
+	stats.PublicKey = peer.PublicKey
+	stats.PresharedKey = peer.PresharedKey
+	stats.AllowedIPs = append([]string(nil), peer.AllowedIPs...)
+	b.peers[peer.PublicKey] = stats
+
+	return nil
+}
+
+// RemovePeer - Peerni xotiradan o'chirish
+func (b *MemoryBackend) RemovePeer(publicKey string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.peers, publicKey)
+	return nil
+}
+
+// ListPeers - Xotiradagi peerlar ro'yxatini olish
+func (b *MemoryBackend) ListPeers() ([]PeerStats, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	peers := make([]PeerStats, 0, len(b.peers))
+	for _, peer := range b.peers {
+		peers = append(peers, peer)
+	}
+
+	// Natija tartibi barqaror bo'lishi uchun saralash
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].PublicKey < peers[j].PublicKey
+	})
+
+	return peers, nil
+}
+
+// InterfaceInfo - Soxta interface ma'lumotlarini qaytarish
+func (b *MemoryBackend) InterfaceInfo() (*InterfaceInfo, error) {
+	return &InterfaceInfo{Name: b.iface}, nil
+}
+
+// SetPeerStats - Peer statistikasini qo'lda o'rnatish (testlar uchun)
+func (b *MemoryBackend) SetPeerStats(stats PeerStats) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.peers[stats.PublicKey] = stats
+}
@@ -0,0 +1,129 @@
+package wireguard
+
+import (
+	"fmt"
+	"net"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// NetlinkBackend - wgctrl orqali kernel bilan to'g'ridan-to'g'ri ishlaydigan backend.
+// wireguard-tools o'rnatilishini talab qilmaydi, lekin o'zgarishlar wg0.conf
+// fayliga yozilmaydi: server ishga tushganda peerlar databasedan tiklanadi (reconcile.Restore),
+// server ishlayotganda interface qayta ishga tushsa esa faqat drift tekshiruvi auto_repair bilan tiklaydi.
+type NetlinkBackend struct {
+	iface  string
+	client *wgctrl.Client
+}
+
+// NewNetlinkBackend - Yangi NetlinkBackend yaratish
+func NewNetlinkBackend(iface string) (*NetlinkBackend, error) {
+	client, err := wgctrl.New()
+	if err != nil {
+		return nil, fmt.Errorf("wgctrl clientini yaratishda xatolik: %v", err)
+	}
+
+	return &NetlinkBackend{iface: iface, client: client}, nil
+}
+
+// AddPeer - Peerni qo'shish yoki yangilash
+func (b *NetlinkBackend) AddPeer(peer Peer) error {
+	publicKey, err := wgtypes.ParseKey(peer.PublicKey)
+	if err != nil {
+		return fmt.Errorf("public key noto'g'ri: %v", err)
+	}
+
+	peerConfig := wgtypes.PeerConfig{
+		PublicKey:         publicKey,
+		ReplaceAllowedIPs: true,
+	}
+
+	if peer.PresharedKey != "" {
+		presharedKey, err := wgtypes.ParseKey(peer.PresharedKey)
+		if err != nil {
+			return fmt.Errorf("preshared key noto'g'ri: %v", err)
+		}
+		peerConfig.PresharedKey = &presharedKey
+	}
+
+	for _, allowedIP := range peer.AllowedIPs {
+		_, ipNet, err := net.ParseCIDR(allowedIP)
+		if err != nil {
+			return fmt.Errorf("allowed-ip noto'g'ri: %s: %v", allowedIP, err)
+		}
+		peerConfig.AllowedIPs = append(peerConfig.AllowedIPs, *ipNet)
+	}
+
+	err = b.client.ConfigureDevice(b.iface, wgtypes.Config{Peers: []wgtypes.PeerConfig{peerConfig}})
+	if err != nil {
+		return fmt.Errorf("peer qo'shishda xatolik: %v", err)
+	}
+
+	return nil
+}
+
+// RemovePeer - Peerni interfacedan o'chirish
+func (b *NetlinkBackend) RemovePeer(publicKey string) error {
+	key, err := wgtypes.ParseKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("public key noto'g'ri: %v", err)
+	}
+
+	err = b.client.ConfigureDevice(b.iface, wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{PublicKey: key, Remove: true}},
+	})
+	if err != nil {
+		return fmt.Errorf("peerni o'chirishda xatolik: %v", err)
+	}
+
+	return nil
+}
+
+// ListPeers - Interfacedagi peerlar ro'yxatini olish
+func (b *NetlinkBackend) ListPeers() ([]PeerStats, error) {
+	device, err := b.client.Device(b.iface)
+	if err != nil {
+		return nil, fmt.Errorf("interface ma'lumotlarini olishda xatolik: %v", err)
+	}
+
+	var peers []PeerStats
+	for _, p := range device.Peers {
+		stats := PeerStats{
+			PublicKey:           p.PublicKey.String(),
+			LatestHandshake:     p.LastHandshakeTime,
+			BytesReceived:       p.ReceiveBytes,
+			BytesSent:           p.TransmitBytes,
+			PersistentKeepalive: int(p.PersistentKeepaliveInterval.Seconds()),
+		}
+
+		// Nol kalit preshared key o'rnatilmaganini bildiradi
+		if p.PresharedKey != (wgtypes.Key{}) {
+			stats.PresharedKey = p.PresharedKey.String()
+		}
+		if p.Endpoint != nil {
+			stats.Endpoint = p.Endpoint.String()
+		}
+		for _, allowedIP := range p.AllowedIPs {
+			stats.AllowedIPs = append(stats.AllowedIPs, allowedIP.String())
+		}
+
+		peers = append(peers, stats)
+	}
+
+	return peers, nil
+}
+
+// InterfaceInfo - Interface haqida ma'lumot olish
+func (b *NetlinkBackend) InterfaceInfo() (*InterfaceInfo, error) {
+	device, err := b.client.Device(b.iface)
+	if err != nil {
+		return nil, fmt.Errorf("interface ma'lumotlarini olishda xatolik: %v", err)
+	}
+
+	return &InterfaceInfo{
+		Name:       device.Name,
+		PublicKey:  device.PublicKey.String(),
+		ListenPort: device.ListenPort,
+	}, nil
+}
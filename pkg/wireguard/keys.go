@@ -0,0 +1,76 @@
+package wireguard
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// KeyLen - Wireguard kalitining uzunligi (baytlarda)
+const KeyLen = 32
+
+// GenerateKeyPair - Yangi client uchun private va public key yaratish.
+// Kalitlar `wg genkey | wg pubkey` bilan bir xil base64 formatda qaytariladi.
+func GenerateKeyPair() (string, string, error) {
+	var privateKey [KeyLen]byte
+	if _, err := rand.Read(privateKey[:]); err != nil {
+		return "", "", fmt.Errorf("private key yaratishda xatolik: %v", err)
+	}
+
+	// Curve25519 clamping (wg genkey bilan bir xil)
+	privateKey[0] &= 248
+	privateKey[31] = (privateKey[31] & 127) | 64
+
+	encodedPrivateKey := base64.StdEncoding.EncodeToString(privateKey[:])
+	publicKey, err := PublicKeyFromPrivate(encodedPrivateKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	return encodedPrivateKey, publicKey, nil
+}
+
+// PublicKeyFromPrivate - Private keydan public key hisoblash (wg pubkey analogi)
+func PublicKeyFromPrivate(privateKey string) (string, error) {
+	raw, err := decodeKey(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("private key noto'g'ri: %v", err)
+	}
+
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return "", fmt.Errorf("public key yaratishda xatolik: %v", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// GeneratePresharedKey - Preshared key yaratish (wg genpsk analogi)
+func GeneratePresharedKey() (string, error) {
+	var presharedKey [KeyLen]byte
+	if _, err := rand.Read(presharedKey[:]); err != nil {
+		return "", fmt.Errorf("preshared key yaratishda xatolik: %v", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(presharedKey[:]), nil
+}
+
+// ValidateKey - Kalit 32 baytli base64 formatda ekanligini tekshirish
+func ValidateKey(key string) error {
+	_, err := decodeKey(key)
+	return err
+}
+
+// decodeKey - Base64 kalitni baytlarga o'tkazish
+func decodeKey(key string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("kalit base64 formatida emas")
+	}
+	if len(raw) != KeyLen {
+		return nil, fmt.Errorf("kalit uzunligi %d bayt bo'lishi kerak, %d bayt berildi", KeyLen, len(raw))
+	}
+
+	return raw, nil
+}
@@ -1,13 +1,9 @@
 package wireguard
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
-	"strconv"
 	"strings"
 	"time"
 
@@ -32,69 +28,16 @@ func GetServerPublicKey() (string, error) {
 	}
 
 	// Qator so'ngidagi bo'sh joylarni olib tashlash
-	return strings.TrimSpace(string(publicKey)), nil
-}
-
-// GenerateKeyPair - Yangi client uchun private va public key yaratish
-func GenerateKeyPair() (string, string, error) {
-	// Private key yaratish
-	privateKeyCmd := exec.Command("wg", "genkey")
-	privateKeyOutput, err := privateKeyCmd.Output()
-	if err != nil {
-		return "", "", fmt.Errorf("private key yaratishda xatolik: %v", err)
-	}
-	privateKey := strings.TrimSpace(string(privateKeyOutput))
-
-	// Private keydan public key yaratish
-	publicKeyCmd := exec.Command("wg", "pubkey")
-	publicKeyCmd.Stdin = bytes.NewBufferString(privateKey)
-	publicKeyOutput, err := publicKeyCmd.Output()
-	if err != nil {
-		return "", "", fmt.Errorf("public key yaratishda xatolik: %v", err)
-	}
-	publicKey := strings.TrimSpace(string(publicKeyOutput))
-
-	return privateKey, publicKey, nil
-}
-
-// FindAvailableIP - Bo'sh IP manzilni topish
-func FindAvailableIP(clientType models.ClientType, usedIPs []string) (string, error) {
-	var subnetPrefix string
-
-	// Client turiga qarab subnet tanlash
-	if clientType == models.ClientTypeVIP {
-		// VIP clientlar uchun 10.77.x.x subnet
-		subnetPrefix = "10.77."
-	} else {
-		// Normal clientlar uchun 10.7.x.x subnet
-		subnetPrefix = "10.7."
-	}
-
-	// IP manzillarni map ga o'tkazish (tezroq qidirish uchun)
-	usedIPMap := make(map[string]bool)
-	for _, ip := range usedIPs {
-		usedIPMap[ip] = true
+	serverPublicKey := strings.TrimSpace(string(publicKey))
+	if err := ValidateKey(serverPublicKey); err != nil {
+		return "", fmt.Errorf("server public key noto'g'ri: %v", err)
 	}
 
-	// Bo'sh IP manzilni topish
-	// Avval 10.x.0.2 dan 10.x.0.251 gacha tekshirish
-	for thirdOctet := 0; thirdOctet <= 255; thirdOctet++ {
-		for fourthOctet := 2; fourthOctet <= 251; fourthOctet++ {
-			candidateIP := fmt.Sprintf("%s%d.%d", subnetPrefix, thirdOctet, fourthOctet)
-
-			// Agar bu IP manzil ishlatilmayotgan bo'lsa, uni qaytarish
-			if !usedIPMap[candidateIP] {
-				log.Printf("Yangi IP manzil yaratildi: %s", candidateIP)
-				return candidateIP + "/32", nil
-			}
-		}
-	}
-
-	// Agar barcha IP manzillar band bo'lsa, xatolik qaytarish
-	return "", fmt.Errorf("bo'sh IP manzil topilmadi")
+	return serverPublicKey, nil
 }
 
-// CreateClientConfig - Wireguard client konfiguratsiyasini yaratish
+// CreateClientConfig - Wireguard client konfiguratsiyasini yaratish.
+// clientIP bir nechta manzilni vergul bilan ajratilgan holda qabul qiladi (masalan "10.7.0.2/32, fd00:7::2/128").
 func CreateClientConfig(clientPrivateKey, presharedKey, clientIP, serverPublicKey string) (string, models.WireguardConfig) {
 	// Endpoint yaratish
 	endpoint := fmt.Sprintf("%s:%d", config.Config.Server.IP, config.Config.Server.Port)
@@ -126,72 +69,6 @@ PersistentKeepalive = %d
 	return configText, clientConfig
 }
 
-// AddPeerToServer - Server konfiguratsiyasiga yangi peer qo'shish
-func AddPeerToServer(clientPublicKey, clientIP, presharedKey string) error {
-	// IP manzilni formatlash (CIDR notatsiyasini olib tashlash)
-	clientIPWithoutCIDR := strings.Split(clientIP, "/")[0]
-
-	// Preshared key faylini yaratish
-	tempPresharedKeyFile, err := os.CreateTemp("", "psk")
-	if err != nil {
-		return fmt.Errorf("vaqtinchalik preshared key fayli yaratishda xatolik: %v", err)
-	}
-	defer os.Remove(tempPresharedKeyFile.Name())
-
-	_, err = tempPresharedKeyFile.WriteString(presharedKey)
-	if err != nil {
-		return fmt.Errorf("preshared key fayliga yozishda xatolik: %v", err)
-	}
-	tempPresharedKeyFile.Close()
-
-	// wg-quick orqali yangi peer qo'shish
-	cmd := exec.Command("wg", "set", config.Config.Server.Interface, "peer", clientPublicKey, "preshared-key", tempPresharedKeyFile.Name(), "allowed-ips", clientIPWithoutCIDR+"/32")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("peer qo'shishda xatolik: %v, output: %s", err, string(output))
-	}
-
-	// O'zgarishlarni saqlash
-	cmd = exec.Command("bash", "-c", fmt.Sprintf("wg-quick save %s", config.Config.Server.Interface))
-	output, err = cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("konfiguratsiyani saqlashda xatolik: %v, output: %s", err, string(output))
-	}
-
-	return nil
-}
-
-// GeneratePresharedKey - Preshared key yaratish
-func GeneratePresharedKey() (string, error) {
-	// Preshared key yaratish
-	presharedKeyCmd := exec.Command("wg", "genpsk")
-	presharedKeyOutput, err := presharedKeyCmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("preshared key yaratishda xatolik: %v", err)
-	}
-	presharedKey := strings.TrimSpace(string(presharedKeyOutput))
-	return presharedKey, nil
-}
-
-// RemovePeerFromServer - Server konfiguratsiyasidan peerni o'chirish
-func RemovePeerFromServer(publicKey string) error {
-	// wg-quick orqali peerni o'chirish
-	cmd := exec.Command("wg", "set", config.Config.Server.Interface, "peer", publicKey, "remove")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("peerni o'chirishda xatolik: %v, output: %s", err, string(output))
-	}
-
-	// O'zgarishlarni saqlash
-	cmd = exec.Command("bash", "-c", fmt.Sprintf("wg-quick save %s", config.Config.Server.Interface))
-	output, err = cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("konfiguratsiyani saqlashda xatolik: %v, output: %s", err, string(output))
-	}
-
-	return nil
-}
-
 // ClientTraffic - Client traffic ma'lumotlari
 type ClientTraffic struct {
 	PublicKey              string    `json:"public_key"`
@@ -203,95 +80,15 @@ type ClientTraffic struct {
 	BytesSentFormatted     string    `json:"bytes_sent_formatted"`
 }
 
-// WgJsonOutput - wg-json buyrug'i natijasi
-type WgJsonOutput map[string]WgInterface
-
-// WgInterface - Wireguard interface ma'lumotlari
-type WgInterface struct {
-	PrivateKey string                `json:"privateKey"`
-	PublicKey  string                `json:"publicKey"`
-	ListenPort int                   `json:"listenPort"`
-	Peers      map[string]WgPeerInfo `json:"peers"`
-}
-
-// WgPeerInfo - Wireguard peer ma'lumotlari
-type WgPeerInfo struct {
-	PresharedKey    string   `json:"presharedKey"`
-	Endpoint        string   `json:"endpoint,omitempty"`
-	LatestHandshake int64    `json:"latestHandshake,omitempty"`
-	TransferRx      int64    `json:"transferRx,omitempty"`
-	TransferTx      int64    `json:"transferTx,omitempty"`
-	AllowedIps      []string `json:"allowedIps,omitempty"`
-}
-
-// GetWgJsonData - wg-json buyrug'i orqali ma'lumotlarni olish
-func GetWgJsonData() (WgJsonOutput, error) {
-	// wg-json buyrug'ini ishga tushirish
-	cmd := exec.Command("wg-json")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("wg-json buyrug'ini ishga tushirishda xatolik: %v", err)
-	}
-
-	// JSON ma'lumotlarini parse qilish
-	var wgData WgJsonOutput
-	if err := json.Unmarshal(output, &wgData); err != nil {
-		return nil, fmt.Errorf("JSON ma'lumotlarini parse qilishda xatolik: %v", err)
-	}
-
-	return wgData, nil
-}
-
 // GetClientTraffic - Client traffic ma'lumotlarini olish
-func GetClientTraffic(publicKey string) (*ClientTraffic, error) {
-	// Konfiguratsiyadan interface nomini olish
-	interfaceName := config.Config.Server.Interface
-
-	// Default qiymatni o'rnatish
-	if interfaceName == "" {
-		interfaceName = "wg0"
-	}
-
-	// wg komandasi orqali traffic ma'lumotlarini olish
-	cmd := exec.Command("wg", "show", interfaceName, "dump")
-	output, err := cmd.Output()
+func GetClientTraffic(backend Backend, publicKey string) (*ClientTraffic, error) {
+	trafficList, err := GetAllClientsTraffic(backend)
 	if err != nil {
-		return nil, fmt.Errorf("traffic ma'lumotlarini olishda xatolik: %v", err)
+		return nil, err
 	}
 
-	// Natijani qayta ishlash
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		fields := strings.Fields(line)
-		if len(fields) >= 5 && fields[0] == publicKey {
-			// Handshake vaqtini o'zgartirish
-			handshakeUnix, _ := strconv.ParseInt(fields[4], 10, 64)
-			var handshakeTime time.Time
-			if handshakeUnix > 0 {
-				handshakeTime = time.Unix(handshakeUnix, 0)
-			}
-
-			// Traffic ma'lumotlarini o'zgartirish
-			bytesReceived, _ := strconv.ParseInt(fields[5], 10, 64)
-			bytesSent, _ := strconv.ParseInt(fields[6], 10, 64)
-
-			// AllowedIPs ni olish
-			allowedIPs := ""
-			if len(fields) >= 8 {
-				allowedIPs = fields[7]
-			}
-
-			// Traffic obyektini yaratish
-			traffic := &ClientTraffic{
-				PublicKey:              publicKey,
-				LatestHandshake:        handshakeTime,
-				BytesReceived:          bytesReceived,
-				BytesSent:              bytesSent,
-				AllowedIPs:             allowedIPs,
-				BytesReceivedFormatted: formatBytes(bytesReceived),
-				BytesSentFormatted:     formatBytes(bytesSent),
-			}
-
+	for _, traffic := range trafficList {
+		if traffic.PublicKey == publicKey {
 			return traffic, nil
 		}
 	}
@@ -300,65 +97,25 @@ func GetClientTraffic(publicKey string) (*ClientTraffic, error) {
 }
 
 // GetAllClientsTraffic - Barcha clientlar traffic ma'lumotlarini olish
-func GetAllClientsTraffic() ([]*ClientTraffic, error) {
-	// Konfiguratsiyadan interface nomini olish
-	interfaceName := config.Config.Server.Interface
-
-	// Default qiymatni o'rnatish
-	if interfaceName == "" {
-		interfaceName = "wg0"
-	}
-
-	// wg komandasi orqali traffic ma'lumotlarini olish
-	cmd := exec.Command("wg", "show", interfaceName, "dump")
-	output, err := cmd.Output()
+func GetAllClientsTraffic(backend Backend) ([]*ClientTraffic, error) {
+	peers, err := backend.ListPeers()
 	if err != nil {
 		return nil, fmt.Errorf("traffic ma'lumotlarini olishda xatolik: %v", err)
 	}
 
-	// Natijani qayta ishlash
 	var trafficList []*ClientTraffic
-	lines := strings.Split(string(output), "\n")
-
-	// Birinchi qatorni o'tkazib yuborish (interface ma'lumotlari)
-	for i := 1; i < len(lines); i++ {
-		line := lines[i]
-		if line == "" {
-			continue
+	for _, peer := range peers {
+		traffic := &ClientTraffic{
+			PublicKey:              peer.PublicKey,
+			LatestHandshake:        peer.LatestHandshake,
+			BytesReceived:          peer.BytesReceived,
+			BytesSent:              peer.BytesSent,
+			AllowedIPs:             strings.Join(peer.AllowedIPs, ","),
+			BytesReceivedFormatted: formatBytes(peer.BytesReceived),
+			BytesSentFormatted:     formatBytes(peer.BytesSent),
 		}
 
-		fields := strings.Fields(line)
-		if len(fields) >= 7 {
-			// Handshake vaqtini o'zgartirish
-			handshakeUnix, _ := strconv.ParseInt(fields[4], 10, 64)
-			var handshakeTime time.Time
-			if handshakeUnix > 0 {
-				handshakeTime = time.Unix(handshakeUnix, 0)
-			}
-
-			// Traffic ma'lumotlarini o'zgartirish
-			bytesReceived, _ := strconv.ParseInt(fields[5], 10, 64)
-			bytesSent, _ := strconv.ParseInt(fields[6], 10, 64)
-
-			// AllowedIPs ni olish
-			allowedIPs := ""
-			if len(fields) >= 8 {
-				allowedIPs = fields[7]
-			}
-
-			// Traffic obyektini yaratish
-			traffic := &ClientTraffic{
-				PublicKey:              fields[0],
-				LatestHandshake:        handshakeTime,
-				BytesReceived:          bytesReceived,
-				BytesSent:              bytesSent,
-				AllowedIPs:             allowedIPs,
-				BytesReceivedFormatted: formatBytes(bytesReceived),
-				BytesSentFormatted:     formatBytes(bytesSent),
-			}
-
-			trafficList = append(trafficList, traffic)
-		}
+		trafficList = append(trafficList, traffic)
 	}
 
 	return trafficList, nil
@@ -417,73 +174,39 @@ type ServerStatus struct {
 }
 
 // GetServerStatus - Server holatini olish
-func GetServerStatus() (*ServerStatus, error) {
-	// Konfiguratsiyadan interface nomini olish
-	interfaceName := config.Config.Server.Interface
-
-	// Default qiymatni o'rnatish
-	if interfaceName == "" {
-		interfaceName = "wg0"
-	}
-
-	// wg komandasi orqali server ma'lumotlarini olish
-	cmd := exec.Command("wg", "show", interfaceName, "dump")
-	output, err := cmd.Output()
+func GetServerStatus(backend Backend) (*ServerStatus, error) {
+	// Interface ma'lumotlarini olish
+	info, err := backend.InterfaceInfo()
 	if err != nil {
 		return nil, fmt.Errorf("server ma'lumotlarini olishda xatolik: %v", err)
 	}
 
-	// Natijani qayta ishlash
-	lines := strings.Split(string(output), "\n")
-	if len(lines) < 1 {
-		return nil, fmt.Errorf("server ma'lumotlari topilmadi")
-	}
-
-	// Server ma'lumotlarini olish (birinchi qator)
-	serverFields := strings.Fields(lines[0])
-	if len(serverFields) < 3 {
-		return nil, fmt.Errorf("server ma'lumotlari formati noto'g'ri")
+	// Barcha peerlar ma'lumotlarini olish
+	peers, err := backend.ListPeers()
+	if err != nil {
+		return nil, fmt.Errorf("server ma'lumotlarini olishda xatolik: %v", err)
 	}
 
-	// Server ma'lumotlarini o'zgartirish
-	publicKey := serverFields[1]
-	listenPort, _ := strconv.Atoi(serverFields[2])
-
-	// Barcha clientlar ma'lumotlarini olish
 	var totalBytesReceived, totalBytesSent int64
 	var lastHandshake time.Time
 	activeClients := 0
 
-	// Birinchi qatorni o'tkazib yuborish (interface ma'lumotlari)
-	for i := 1; i < len(lines); i++ {
-		line := lines[i]
-		if line == "" {
-			continue
-		}
-
-		fields := strings.Fields(line)
-		if len(fields) >= 7 {
-			// Handshake vaqtini o'zgartirish
-			handshakeUnix, _ := strconv.ParseInt(fields[4], 10, 64)
-			if handshakeUnix > 0 {
-				clientHandshake := time.Unix(handshakeUnix, 0)
-				// Oxirgi handshake vaqtini yangilash
-				if clientHandshake.After(lastHandshake) {
-					lastHandshake = clientHandshake
-				}
-
-				// Aktiv clientlarni hisoblash (oxirgi 3 minut ichida handshake bo'lgan)
-				if time.Since(clientHandshake).Minutes() < 3 {
-					activeClients++
-				}
+	for _, peer := range peers {
+		if !peer.LatestHandshake.IsZero() {
+			// Oxirgi handshake vaqtini yangilash
+			if peer.LatestHandshake.After(lastHandshake) {
+				lastHandshake = peer.LatestHandshake
 			}
 
-			// Traffic ma'lumotlarini qo'shish
-			bytesReceived, _ := strconv.ParseInt(fields[5], 10, 64)
-			bytesSent, _ := strconv.ParseInt(fields[6], 10, 64)
-			totalBytesReceived += bytesReceived
-			totalBytesSent += bytesSent
+			// Aktiv clientlarni hisoblash (oxirgi 3 minut ichida handshake bo'lgan)
+			if time.Since(peer.LatestHandshake).Minutes() < 3 {
+				activeClients++
+			}
 		}
+
+		// Traffic ma'lumotlarini qo'shish
+		totalBytesReceived += peer.BytesReceived
+		totalBytesSent += peer.BytesSent
 	}
 
 	// Uptime ni olish
@@ -496,12 +219,12 @@ func GetServerStatus() (*ServerStatus, error) {
 
 	// Server holati obyektini yaratish
 	status := &ServerStatus{
-		InterfaceName:               interfaceName,
-		ListenPort:                  listenPort,
-		PublicKey:                   publicKey,
+		InterfaceName:               info.Name,
+		ListenPort:                  info.ListenPort,
+		PublicKey:                   info.PublicKey,
 		PrivateKey:                  "", // Xavfsizlik uchun private key ni qaytarmaymiz
 		ActiveClients:               activeClients,
-		TotalClients:                len(lines) - 1, // Birinchi qator server ma'lumotlari
+		TotalClients:                len(peers),
 		TotalBytesReceived:          totalBytesReceived,
 		TotalBytesSent:              totalBytesSent,
 		TotalTraffic:                totalBytesReceived + totalBytesSent,
@@ -514,3 +237,12 @@ func GetServerStatus() (*ServerStatus, error) {
 
 	return status, nil
 }
+
+// PeerFromClient - Database dagi client uchun interfacedagi kutilgan peer konfiguratsiyasi
+func PeerFromClient(client *models.WireguardClient) Peer {
+	return Peer{
+		PublicKey:    client.PublicKey,
+		PresharedKey: client.PresharedKey,
+		AllowedIPs:   client.Addresses(),
+	}
+}